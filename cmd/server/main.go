@@ -7,6 +7,7 @@ import (
 
 	"github.com/benbeisheim/minechess-backend/internal/controller"
 	"github.com/benbeisheim/minechess-backend/internal/middleware"
+	"github.com/benbeisheim/minechess-backend/internal/repository"
 	"github.com/benbeisheim/minechess-backend/internal/service"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -49,8 +50,19 @@ func main() {
 		return c.Next()
 	})
 
+	// Open the game database
+	dbPath := os.Getenv("DB_PATH")
+	if dbPath == "" {
+		dbPath = "minechess.db"
+	}
+	gameRepository, err := repository.NewSQLiteGameRepository(dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer gameRepository.Close()
+
 	// Initialize services
-	gameManager := service.NewGameManager()
+	gameManager := service.NewGameManager(gameRepository, gameRepository)
 	gameService := service.NewGameService(gameManager)
 
 	// Initialize controllers
@@ -78,7 +90,13 @@ func main() {
 	gameRoutes.Post("/create", gameController.CreateGame)
 	gameRoutes.Post("/join/:gameId", gameController.JoinGame)
 	gameRoutes.Get("/:gameId", gameController.GetGameState)
+	gameRoutes.Get("/:gameId/archive", gameController.GetArchivedGame)
+	gameRoutes.Get("/:gameId/pgn", gameController.GetPGN)
 	gameRoutes.Get("/matchmaking/events", gameController.HandleMatchmakingEvents)
 
+	// Player routes
+	playerRoutes := api.Group("/players")
+	playerRoutes.Get("/:id/games", gameController.ListPlayerGames)
+
 	log.Fatal(app.Listen(":3000"))
 }
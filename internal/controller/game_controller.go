@@ -2,9 +2,13 @@ package controller
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 
+	"github.com/benbeisheim/minechess-backend/internal/model"
+	"github.com/benbeisheim/minechess-backend/internal/repository"
 	"github.com/benbeisheim/minechess-backend/internal/service"
+	"github.com/benbeisheim/minechess-backend/pkg/utils/chess"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -16,10 +20,52 @@ func NewGameController(gameService *service.GameService) *GameController {
 	return &GameController{gameService: gameService}
 }
 
+// gameSettingsRequest is the optional body of create and matchmaking requests
+type gameSettingsRequest struct {
+	TimeControl *model.TimeControl `json:"timeControl"`
+	FEN         string             `json:"fen"`   // custom start position, create only
+	Rules       model.Rules        `json:"rules"` // create only
+}
+
+func parseGameSettings(c *fiber.Ctx) (gameSettingsRequest, error) {
+	var req gameSettingsRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return req, err
+		}
+	}
+	if req.TimeControl == nil {
+		timeControl := model.DefaultTimeControl()
+		req.TimeControl = &timeControl
+	}
+	if err := req.TimeControl.Validate(); err != nil {
+		return req, err
+	}
+	if req.FEN != "" {
+		if _, _, err := chess.ParseFEN(req.FEN); err != nil {
+			return req, err
+		}
+	}
+	return req, nil
+}
+
 func (gc *GameController) CreateGame(c *fiber.Ctx) error {
+	req, err := parseGameSettings(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
-	gameID, err := gc.gameService.CreateGame()
+	gameID, err := gc.gameService.CreateGame(*req.TimeControl, req.FEN, req.Rules)
 	if err != nil {
+		// the start position breaks the game's rules
+		var gameErr *model.GameError
+		if errors.As(err, &gameErr) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -51,9 +97,15 @@ func (gc *GameController) JoinGame(c *fiber.Ctx) error {
 
 func (gc *GameController) GetGameState(c *fiber.Ctx) error {
 	gameID := c.Params("gameId")
+	playerID, _ := c.Locals("playerID").(string)
 
-	gameState, err := gc.gameService.GetGameState(gameID)
+	gameState, err := gc.gameService.GetGameState(gameID, playerID)
 	if err != nil {
+		if errors.Is(err, model.ErrDelayed) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		if err.Error() == "game not found" {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": err.Error(),
@@ -67,11 +119,92 @@ func (gc *GameController) GetGameState(c *fiber.Ctx) error {
 	return c.JSON(gameState)
 }
 
+func (gc *GameController) GetArchivedGame(c *fiber.Ctx) error {
+	gameID := c.Params("gameId")
+
+	game, err := gc.gameService.GetArchivedGame(gameID)
+	if err != nil {
+		if errors.Is(err, repository.ErrGameNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch archived game",
+		})
+	}
+
+	return c.JSON(game)
+}
+
+func (gc *GameController) GetPGN(c *fiber.Ctx) error {
+	gameID := c.Params("gameId")
+	playerID, _ := c.Locals("playerID").(string)
+
+	pgn, err := gc.gameService.GetPGN(gameID, playerID)
+	if err != nil {
+		if errors.Is(err, model.ErrDelayed) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if errors.Is(err, repository.ErrGameNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to export game",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "application/x-chess-pgn")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"minechess-%s.pgn\"", gameID))
+	return c.SendString(pgn)
+}
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+func (gc *GameController) ListPlayerGames(c *fiber.Ctx) error {
+	playerID := c.Params("id")
+	page := c.QueryInt("page", 1)
+	pageSize := c.QueryInt("pageSize", defaultPageSize)
+	if page < 1 || pageSize < 1 || pageSize > maxPageSize {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("page must be at least 1 and pageSize between 1 and %d", maxPageSize),
+		})
+	}
+
+	games, total, err := gc.gameService.ListPlayerGames(playerID, model.PlayerResult(c.Query("result")), page, pageSize)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"games":    games,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
 func (gc *GameController) JoinMatchmaking(c *fiber.Ctx) error {
 	playerID := c.Locals("playerID").(string)
 	fmt.Println("Adding player to matchmaking queue:", playerID)
 
-	if err := gc.gameService.JoinMatchmaking(playerID); err != nil {
+	req, err := parseGameSettings(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := gc.gameService.JoinMatchmaking(playerID, *req.TimeControl); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to join matchmaking",
 		})
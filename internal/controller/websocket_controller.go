@@ -2,6 +2,7 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 
@@ -28,16 +29,30 @@ func (wsc *WebSocketController) HandleConnection(c *websocket.Conn) {
 	gameID := c.Params("gameId")
 	playerID := c.Locals("playerID").(string)
 
+	// every write goes through conn, which stops writing once this returns
+	conn := ws.NewConnection(c)
+	defer conn.Close(websocket.CloseNormalClosure, "")
+
 	if playerID == "" {
-		wsc.sendError(c, "playerId is required")
-		c.Close()
+		wsc.sendError(conn, "", errors.New("playerId is required"))
 		return
 	}
 
-	// Register this connection with the game
-	if err := wsc.gameService.RegisterConnection(gameID, playerID, c); err != nil {
+	// Register this connection with the game, as a spectator if asked
+	spectator := c.Query("role") == "spectator"
+	register := wsc.gameService.RegisterConnection
+	if spectator {
+		register = wsc.gameService.RegisterSpectator
+	}
+	err := register(gameID, playerID, conn)
+	if errors.Is(err, model.ErrNotInGame) && !spectator {
+		// the game is full, so they can only watch
+		spectator = true
+		err = wsc.gameService.RegisterSpectator(gameID, playerID, conn)
+	}
+	if err != nil {
 		log.Printf("Failed to register connection: %v", err)
-		c.Close()
+		wsc.sendError(conn, "", err)
 		return
 	}
 
@@ -56,34 +71,132 @@ func (wsc *WebSocketController) HandleConnection(c *websocket.Conn) {
 				continue
 			}
 
-			if err := wsc.handleMessage(gameID, playerID, msg); err != nil {
+			if err := wsc.handleMessage(gameID, playerID, spectator, conn, msg); err != nil {
 				log.Printf("handle error: %v", err)
-				wsc.sendError(c, err.Error())
+				wsc.sendError(conn, msg.RequestID, err)
 			}
 		}
 	}
 
-	wsc.gameService.UnregisterConnection(gameID, playerID)
+	if spectator {
+		wsc.gameService.UnregisterSpectator(gameID, conn)
+	} else {
+		wsc.gameService.UnregisterConnection(gameID, playerID, conn)
+	}
 }
 
-func (wsc *WebSocketController) handleMessage(gameID, playerID string, msg ws.Message) error {
+func (wsc *WebSocketController) handleMessage(gameID, playerID string, spectator bool, conn *ws.Connection, msg ws.Message) error {
 	fmt.Println("Handling message:", msg.Type)
+	// anyone watching may fall behind
+	if msg.Type == ws.MessageTypeResync {
+		return wsc.gameService.HandleResync(gameID, conn)
+	}
+	if spectator {
+		return model.ErrReadOnly
+	}
 	switch msg.Type {
 	case ws.MessageTypeMove:
+		var move model.WSMove
+		err := json.Unmarshal(msg.Payload, &move)
+		if err == nil {
+			err = wsc.gameService.HandleMove(gameID, playerID, msg.RequestID, move)
+		}
+		// a tagged move is always answered, so the client knows what became of it
+		if msg.RequestID == "" {
+			return err
+		}
+		wsc.sendMoveResult(conn, msg.RequestID, err)
+		return nil
+
+	case ws.MessageTypePremove:
 		var move model.WSMove
 		if err := json.Unmarshal(msg.Payload, &move); err != nil {
 			return err
 		}
-		return wsc.gameService.HandleMove(gameID, playerID, move)
+		return wsc.gameService.HandlePremove(gameID, playerID, move)
+
+	case ws.MessageTypeCancelPremove:
+		return wsc.gameService.HandleCancelPremove(gameID, playerID)
+
+	case ws.MessageTypeTakebackRequest:
+		return wsc.gameService.HandleTakebackRequest(gameID, playerID)
+
+	case ws.MessageTypeTakebackAccept:
+		return wsc.gameService.HandleTakebackAccept(gameID, playerID)
+
+	case ws.MessageTypeTakebackDecline:
+		return wsc.gameService.HandleTakebackDecline(gameID, playerID)
+
+	case ws.MessageTypeRematchOffer:
+		return wsc.gameService.HandleRematchOffer(gameID, playerID)
+
+	case ws.MessageTypeRematchAccept:
+		return wsc.gameService.HandleRematchAccept(gameID, playerID)
+
+	case ws.MessageTypeResign:
+		return wsc.gameService.HandleResign(gameID, playerID)
+
+	case ws.MessageTypeDrawOffer:
+		return wsc.gameService.HandleDrawOffer(gameID, playerID)
+
+	case ws.MessageTypeDrawAccept, ws.MessageTypeDraw:
+		return wsc.gameService.HandleDrawAccept(gameID, playerID)
+
+	case ws.MessageTypeDrawDecline:
+		return wsc.gameService.HandleDrawDecline(gameID, playerID)
+
+	case ws.MessageTypeDrawWithdraw:
+		return wsc.gameService.HandleDrawWithdraw(gameID, playerID)
+
+	case ws.MessageTypeDrawClaim:
+		return wsc.gameService.HandleDrawClaim(gameID, playerID)
+
+	case ws.MessageTypeAbort:
+		return wsc.gameService.HandleAbort(gameID, playerID)
+
+	case ws.MessageTypeDisconnectClaim:
+		var claim struct {
+			Claim model.DisconnectClaim `json:"claim"`
+		}
+		if err := json.Unmarshal(msg.Payload, &claim); err != nil {
+			return err
+		}
+		return wsc.gameService.HandleDisconnectClaim(gameID, playerID, claim.Claim)
 
 	default:
 		return fmt.Errorf("unknown message type: %s", msg.Type)
 	}
 }
 
-func (wsc *WebSocketController) sendError(c *websocket.Conn, errorMsg string) {
-	c.WriteJSON(ws.Message{
-		Type:    ws.MessageTypeError,
-		Payload: json.RawMessage(errorMsg),
+// sendMoveResult answers a move sent with a request ID
+func (wsc *WebSocketController) sendMoveResult(conn *ws.Connection, requestID string, err error) {
+	if err == nil {
+		conn.Send(ws.Message{Type: ws.MessageTypeMoveAccepted, RequestID: requestID})
+		return
+	}
+	payload, marshalErr := json.Marshal(model.AsGameError(err))
+	if marshalErr != nil {
+		log.Printf("failed to marshal error: %v", marshalErr)
+		return
+	}
+	conn.Send(ws.Message{
+		Type:      ws.MessageTypeMoveRejected,
+		Payload:   payload,
+		RequestID: requestID,
+	})
+}
+
+// sendError reports a failed command to the client. Errors raised by the game
+// keep their code; anything else is reported as a bad request.
+func (wsc *WebSocketController) sendError(conn *ws.Connection, requestID string, err error) {
+	payload, marshalErr := json.Marshal(model.AsGameError(err))
+	if marshalErr != nil {
+		log.Printf("failed to marshal error: %v", marshalErr)
+		return
+	}
+	conn.Send(ws.Message{
+		Type:      ws.MessageTypeError,
+		Payload:   payload,
+		RequestID: requestID,
 	})
 }
@@ -0,0 +1,100 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// ArchivedGame is the permanent record of a finished game
+type ArchivedGame struct {
+	ID          string      `json:"id"`
+	StartedAt   time.Time   `json:"startedAt"`
+	EndedAt     time.Time   `json:"endedAt"`
+	White       string      `json:"white"`
+	Black       string      `json:"black"`
+	TimeControl TimeControl `json:"timeControl"`
+	Winner      string      `json:"winner"` // "white", "black", or "" for a draw
+	Reason      string      `json:"reason"` // the final Resolve string, e.g. "white wins by Bombmate"
+	MoveHistory []Move      `json:"moveHistory"`
+	StartFEN    string      `json:"startFen,omitempty"` // set when the game didn't start from the standard position
+	Rules       Rules       `json:"rules"`
+}
+
+// PlayerResult is a game result from one player's point of view
+type PlayerResult string
+
+const (
+	PlayerResultWin  PlayerResult = "win"
+	PlayerResultLoss PlayerResult = "loss"
+	PlayerResultDraw PlayerResult = "draw"
+)
+
+func (a ArchivedGame) ResultFor(playerID string) PlayerResult {
+	switch {
+	case a.Winner == "":
+		return PlayerResultDraw
+	case (a.Winner == "white" && a.White == playerID) || (a.Winner == "black" && a.Black == playerID):
+		return PlayerResultWin
+	}
+	return PlayerResultLoss
+}
+
+// winnerOf reads the winning colour out of a Resolve string
+func winnerOf(resolve string) string {
+	for _, color := range []string{"white", "black"} {
+		if strings.HasPrefix(resolve, color+" wins") {
+			return color
+		}
+	}
+	return ""
+}
+
+// NewArchivedGame builds the archive record of a finished game, filling in the
+// mine placed with each ply
+func NewArchivedGame(s GameSnapshot) ArchivedGame {
+	reason := ""
+	if s.State.Resolve != nil {
+		reason = *s.State.Resolve
+	}
+	startedAt := s.StartedAt
+	if startedAt.IsZero() {
+		startedAt = s.CreatedAt
+	}
+
+	moves := make([]Move, len(s.State.MoveHistory))
+	copy(moves, s.State.MoveHistory)
+	ply := 0
+	for i := range moves {
+		// a game set up with black to move has no first white ply
+		if moves[i].WhitePly.Piece != nil {
+			moves[i].WhitePly.Mine, _ = mineAt(s.Mines, ply)
+			ply++
+		}
+		if moves[i].BlackPly.Piece != nil {
+			moves[i].BlackPly.Mine, _ = mineAt(s.Mines, ply)
+			ply++
+		}
+	}
+
+	return ArchivedGame{
+		ID:          s.ID,
+		StartedAt:   startedAt,
+		EndedAt:     s.EndedAt,
+		White:       s.State.Players.White.ID,
+		Black:       s.State.Players.Black.ID,
+		TimeControl: s.TimeControl,
+		Winner:      winnerOf(reason),
+		Reason:      reason,
+		MoveHistory: moves,
+		StartFEN:    s.StartFEN,
+		Rules:       s.State.Rules,
+	}
+}
+
+func mineAt(mines []*Position, ply int) (*Position, bool) {
+	if ply >= len(mines) || mines[ply] == nil {
+		return nil, false
+	}
+	mine := *mines[ply]
+	return &mine, true
+}
@@ -1,6 +1,10 @@
 package model
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/benbeisheim/minechess-backend/pkg/utils/chess"
+)
 
 type PieceType string
 
@@ -62,32 +66,52 @@ func (p Position) getFileNotation() string {
 	return fmt.Sprintf("%c", p.X+97)
 }
 
-func newBoard() *BoardState {
+func (p Position) toSquare() chess.Square {
+	return chess.Square{X: p.X, Y: p.Y}
+}
+
+func positionFromSquare(sq chess.Square) Position {
+	return Position{X: sq.X, Y: sq.Y}
+}
+
+func pieceFromChess(p chess.Piece, sq chess.Square) *Piece {
+	if p.IsEmpty() {
+		return nil
+	}
+	return &Piece{Type: PieceType(p.Type), Color: string(p.Color), HasMoved: p.HasMoved, Position: positionFromSquare(sq)}
+}
+
+func (b *BoardState) toChessBoard() chess.Board {
+	var board chess.Board
+	for y, row := range b.Board {
+		for x, piece := range row {
+			if piece != nil {
+				board[y][x] = chess.Piece{Type: chess.PieceType(piece.Type), Color: chess.Color(piece.Color), HasMoved: piece.HasMoved}
+			}
+		}
+	}
+	return board
+}
+
+func newBoardFromChess(pos chess.Position) *BoardState {
 	board := &BoardState{}
-	for i := 0; i < 8; i++ {
-		board.Board = append(board.Board, make([]*Piece, 8))
+	for y := 0; y < 8; y++ {
+		row := make([]*Piece, 8)
+		for x := 0; x < 8; x++ {
+			sq := chess.Square{X: x, Y: y}
+			row[x] = pieceFromChess(pos.Board.At(sq), sq)
+		}
+		board.Board = append(board.Board, row)
 	}
-	board.Board[0][0] = &Piece{Type: "rook", Color: "black", HasMoved: false, Position: Position{X: 0, Y: 0}}
-	board.Board[0][7] = &Piece{Type: "rook", Color: "black", HasMoved: false, Position: Position{X: 7, Y: 0}}
-	board.Board[7][0] = &Piece{Type: "rook", Color: "white", HasMoved: false, Position: Position{X: 0, Y: 7}}
-	board.Board[7][7] = &Piece{Type: "rook", Color: "white", HasMoved: false, Position: Position{X: 7, Y: 7}}
-	board.Board[0][1] = &Piece{Type: "knight", Color: "black", HasMoved: false, Position: Position{X: 1, Y: 0}}
-	board.Board[0][6] = &Piece{Type: "knight", Color: "black", HasMoved: false, Position: Position{X: 6, Y: 0}}
-	board.Board[7][1] = &Piece{Type: "knight", Color: "white", HasMoved: false, Position: Position{X: 1, Y: 7}}
-	board.Board[7][6] = &Piece{Type: "knight", Color: "white", HasMoved: false, Position: Position{X: 6, Y: 7}}
-	board.Board[0][2] = &Piece{Type: "bishop", Color: "black", HasMoved: false, Position: Position{X: 2, Y: 0}}
-	board.Board[0][5] = &Piece{Type: "bishop", Color: "black", HasMoved: false, Position: Position{X: 5, Y: 0}}
-	board.Board[7][2] = &Piece{Type: "bishop", Color: "white", HasMoved: false, Position: Position{X: 2, Y: 7}}
-	board.Board[7][5] = &Piece{Type: "bishop", Color: "white", HasMoved: false, Position: Position{X: 5, Y: 7}}
-	board.Board[0][3] = &Piece{Type: "queen", Color: "black", HasMoved: false, Position: Position{X: 3, Y: 0}}
-	board.Board[0][4] = &Piece{Type: "king", Color: "black", HasMoved: false, Position: Position{X: 4, Y: 0}}
-	board.Board[7][3] = &Piece{Type: "queen", Color: "white", HasMoved: false, Position: Position{X: 3, Y: 7}}
-	board.Board[7][4] = &Piece{Type: "king", Color: "white", HasMoved: false, Position: Position{X: 4, Y: 7}}
-	for i := 0; i < 8; i++ {
-		board.Board[1][i] = &Piece{Type: "pawn", Color: "black", HasMoved: false, Position: Position{X: i, Y: 1}}
-		board.Board[6][i] = &Piece{Type: "pawn", Color: "white", HasMoved: false, Position: Position{X: i, Y: 6}}
+	if king, ok := pos.KingSquare(chess.White); ok {
+		board.WhiteKingPosition = positionFromSquare(king)
+	}
+	if king, ok := pos.KingSquare(chess.Black); ok {
+		board.BlackKingPosition = positionFromSquare(king)
 	}
-	board.BlackKingPosition = Position{X: 4, Y: 0}
-	board.WhiteKingPosition = Position{X: 4, Y: 7}
 	return board
 }
+
+func newBoard() *BoardState {
+	return newBoardFromChess(chess.StartingPosition())
+}
@@ -1,13 +1,99 @@
 package model
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 )
 
+type DelayType string
+
+const (
+	DelayNone      DelayType = ""
+	DelaySimple    DelayType = "simple"    // the clock only starts counting down once the delay has passed
+	DelayBronstein DelayType = "bronstein" // time used is given back after the move, up to the delay
+)
+
+// TimeControl describes how much time each side gets. All durations are in seconds.
+// When DaysPerMove is set the game is correspondence: each side gets that many days
+// per move and the other fields are ignored.
+//
+// The clocks only start with the first moves, so each side instead has a
+// deadline for its first move, after which the game is aborted.
+type TimeControl struct {
+	Base           int       `json:"base"`
+	Increment      int       `json:"increment"`
+	Delay          int       `json:"delay"`
+	DelayType      DelayType `json:"delayType"`
+	DaysPerMove    int       `json:"daysPerMove"`
+	FirstMoveWhite int       `json:"firstMoveWhite,omitempty"` // 0 for the default deadline
+	FirstMoveBlack int       `json:"firstMoveBlack,omitempty"`
+}
+
+const defaultFirstMoveDeadline = 30 * time.Second
+
+func DefaultTimeControl() TimeControl {
+	return TimeControl{Base: 1200}
+}
+
+func (tc TimeControl) Validate() error {
+	if tc.DaysPerMove < 0 || tc.Base < 0 || tc.Increment < 0 || tc.Delay < 0 || tc.FirstMoveWhite < 0 || tc.FirstMoveBlack < 0 {
+		return errors.New("time control values cannot be negative")
+	}
+	if tc.DaysPerMove > 0 {
+		return nil
+	}
+	if tc.Base == 0 {
+		return errors.New("time control needs a base time")
+	}
+	switch tc.DelayType {
+	case DelayNone:
+		if tc.Delay != 0 {
+			return errors.New("delay given without a delay type")
+		}
+	case DelaySimple, DelayBronstein:
+	default:
+		return fmt.Errorf("unknown delay type: %s", tc.DelayType)
+	}
+	return nil
+}
+
+// Clock is the time control without its first-move deadlines, which say how
+// long each side may wait rather than what game is being played. The deadlines
+// go back to their defaults.
+func (tc TimeControl) Clock() TimeControl {
+	tc.FirstMoveWhite = 0
+	tc.FirstMoveBlack = 0
+	return tc
+}
+
+// FirstMoveDeadline is how long color has to make their first move. In
+// correspondence games it's the time for a move.
+func (tc TimeControl) FirstMoveDeadline(color string) time.Duration {
+	seconds := tc.FirstMoveWhite
+	if color == "black" {
+		seconds = tc.FirstMoveBlack
+	}
+	switch {
+	case seconds > 0:
+		return time.Duration(seconds) * time.Second
+	case tc.DaysPerMove > 0:
+		return tc.InitialTime()
+	}
+	return defaultFirstMoveDeadline
+}
+
+func (tc TimeControl) InitialTime() time.Duration {
+	if tc.DaysPerMove > 0 {
+		return time.Duration(tc.DaysPerMove) * 24 * time.Hour
+	}
+	return time.Duration(tc.Base) * time.Second
+}
+
 type Clock struct {
 	mu          sync.Mutex
+	timeControl TimeControl
 	timeLeft    time.Duration
 	lastStarted time.Time // When the clock was last started
 	isRunning   bool
@@ -23,10 +109,11 @@ func NewClientClock(initialTime int) *ClientClock {
 	}
 }
 
-func NewClock(initialTime time.Duration) *Clock {
+func NewClock(timeControl TimeControl) *Clock {
 	return &Clock{
-		timeLeft:  initialTime,
-		isRunning: false,
+		timeControl: timeControl,
+		timeLeft:    timeControl.InitialTime(),
+		isRunning:   false,
 	}
 }
 
@@ -41,23 +128,75 @@ func (c *Clock) Start() {
 	}
 }
 
+// Stop ends the current move and applies the increment or delay of the time control
 func (c *Clock) Stop() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if c.isRunning {
-		c.timeLeft -= time.Since(c.lastStarted)
+		c.timeLeft = c.remaining(time.Since(c.lastStarted))
+		if c.timeLeft > 0 {
+			c.timeLeft += c.bonus(time.Since(c.lastStarted))
+		}
 		fmt.Println("clock stopped", c.timeLeft)
 		c.isRunning = false
 	}
 }
 
+// remaining is the time left after spending used on the current move, before any bonus
+func (c *Clock) remaining(used time.Duration) time.Duration {
+	tc := c.timeControl
+	if tc.DaysPerMove > 0 {
+		return tc.InitialTime() - used
+	}
+	if tc.DelayType == DelaySimple {
+		delay := time.Duration(tc.Delay) * time.Second
+		if used <= delay {
+			return c.timeLeft
+		}
+		used -= delay
+	}
+	return c.timeLeft - used
+}
+
+// bonus is the time credited once a move is completed
+func (c *Clock) bonus(used time.Duration) time.Duration {
+	tc := c.timeControl
+	switch {
+	case tc.DaysPerMove > 0:
+		// correspondence clocks are reset for every move
+		return tc.InitialTime() - c.timeLeft
+	case tc.DelayType == DelayBronstein:
+		return min(used, time.Duration(tc.Delay)*time.Second) + time.Duration(tc.Increment)*time.Second
+	}
+	return time.Duration(tc.Increment) * time.Second
+}
+
 func (c *Clock) GetTimeLeft() time.Duration {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if c.isRunning {
-		return c.timeLeft - time.Since(c.lastStarted)
+		return c.remaining(time.Since(c.lastStarted))
 	}
 	return c.timeLeft
 }
+
+// TimeUntilFlag is how long the clock can keep running before it runs out,
+// including whatever is left of a simple delay
+func (c *Clock) TimeUntilFlag() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.isRunning {
+		return c.timeLeft
+	}
+	used := time.Since(c.lastStarted)
+	if c.timeControl.DaysPerMove == 0 && c.timeControl.DelayType == DelaySimple {
+		delay := time.Duration(c.timeControl.Delay) * time.Second
+		if used < delay {
+			return c.timeLeft + delay - used
+		}
+	}
+	return c.remaining(used)
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeControlValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		tc   TimeControl
+		ok   bool
+	}{
+		{"base", TimeControl{Base: 300}, true},
+		{"increment", TimeControl{Base: 180, Increment: 2}, true},
+		{"simple delay", TimeControl{Base: 300, Delay: 5, DelayType: DelaySimple}, true},
+		{"bronstein delay", TimeControl{Base: 300, Delay: 5, DelayType: DelayBronstein}, true},
+		{"correspondence", TimeControl{DaysPerMove: 3}, true},
+		{"no base", TimeControl{Increment: 2}, false},
+		{"negative base", TimeControl{Base: -1}, false},
+		{"negative increment", TimeControl{Base: 300, Increment: -1}, false},
+		{"negative days", TimeControl{DaysPerMove: -1}, false},
+		{"delay without a type", TimeControl{Base: 300, Delay: 5}, false},
+		{"unknown delay type", TimeControl{Base: 300, Delay: 5, DelayType: "fischer"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.tc.Validate(); (err == nil) != tt.ok {
+				t.Errorf("got %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+// spendOnMove runs c for a move that took used, and returns the time left after it
+func spendOnMove(c *Clock, used time.Duration) time.Duration {
+	c.Start()
+	c.mu.Lock()
+	c.lastStarted = c.lastStarted.Add(-used)
+	c.mu.Unlock()
+	c.Stop()
+	return c.GetTimeLeft()
+}
+
+func TestClockAppliesTimeControlOnStop(t *testing.T) {
+	tests := []struct {
+		name string
+		tc   TimeControl
+		used time.Duration
+		want time.Duration
+	}{
+		{"no increment", TimeControl{Base: 60}, 5 * time.Second, 55 * time.Second},
+		{"increment", TimeControl{Base: 60, Increment: 2}, 5 * time.Second, 57 * time.Second},
+		{"simple delay not used up", TimeControl{Base: 60, Delay: 3, DelayType: DelaySimple}, 2 * time.Second, 60 * time.Second},
+		{"simple delay used up", TimeControl{Base: 60, Delay: 3, DelayType: DelaySimple}, 5 * time.Second, 58 * time.Second},
+		{"bronstein delay not used up", TimeControl{Base: 60, Delay: 3, DelayType: DelayBronstein}, 2 * time.Second, 60 * time.Second},
+		{"bronstein delay used up", TimeControl{Base: 60, Delay: 3, DelayType: DelayBronstein}, 5 * time.Second, 58 * time.Second},
+		{"correspondence", TimeControl{DaysPerMove: 2}, 30 * time.Hour, 48 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := spendOnMove(NewClock(tt.tc), tt.used)
+			if diff := got - tt.want; diff < -100*time.Millisecond || diff > 100*time.Millisecond {
+				t.Errorf("time left %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// No increment is given for a move made after the clock ran out
+func TestClockGivesNoIncrementAfterRunningOut(t *testing.T) {
+	c := NewClock(TimeControl{Base: 1, Increment: 5})
+	if got := spendOnMove(c, 2*time.Second); got > 0 {
+		t.Errorf("time left %v, want none", got)
+	}
+}
+
+func TestGameUsesTimeControl(t *testing.T) {
+	tc := TimeControl{Base: 180, Increment: 2}
+	g := NewGame("test", tc, Rules{})
+	state, err := g.GetState("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.TimeControl != tc {
+		t.Errorf("state time control %+v, want %+v", state.TimeControl, tc)
+	}
+	if state.Players.White.TimeLeft != 1800 || state.Players.Black.TimeLeft != 1800 {
+		t.Errorf("time left %d and %d, want 1800 deciseconds each", state.Players.White.TimeLeft, state.Players.Black.TimeLeft)
+	}
+}
@@ -0,0 +1,231 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/benbeisheim/minechess-backend/internal/ws"
+)
+
+// Each connection is sent the full state once, when it registers, and only
+// what changed after that. A delta names the sequence number of the state it
+// applies to, so a client that finds it doesn't hold that state can ask for a
+// resync and start over from a full one.
+
+// StateDelta is the difference between two views of the game
+type StateDelta struct {
+	BaseSeq   uint64                     `json:"baseSeq"` // the state this delta applies to
+	Ply       int                        `json:"ply"`     // plies played; when it changes, so does the position
+	Squares   []Square                   `json:"squares,omitempty"`
+	WhiteKing *Position                  `json:"whiteKingPosition,omitempty"`
+	BlackKing *Position                  `json:"blackKingPosition,omitempty"`
+	History   *HistoryDelta              `json:"history,omitempty"`
+	Fields    map[string]json.RawMessage `json:"fields,omitempty"` // any other changed GameState field, by its JSON name
+}
+
+// HistoryDelta replaces the move history from index From onwards with Moves.
+// Usually that's the last move getting its black ply, or a new move.
+type HistoryDelta struct {
+	From  int    `json:"from"`
+	Moves []Move `json:"moves"`
+}
+
+// sentState is the last state queued to a connection, which its next delta is
+// taken against
+type sentState struct {
+	seq  uint64
+	view GameState
+}
+
+// sendState queues view to conn, as a delta if conn has already been sent a
+// state. Connections sent the same view can share messages, which built keeps
+// by the sequence number they're based on (0 for the full state); it may be
+// nil. The caller must hold gc.mu for writing.
+func (gc *GameConnections) sendState(conn *ws.Connection, view GameState, seq uint64, built map[uint64]ws.Message) error {
+	base, sent := gc.sent[conn]
+	if sent && base.seq >= seq {
+		return nil // a delayed broadcast overtaken by a newer one
+	}
+	msg, ok := built[base.seq]
+	if !ok {
+		var err error
+		if sent {
+			msg, err = deltaMessage(base, view, seq)
+		} else {
+			msg, err = stateMessage(view, seq)
+		}
+		if err != nil {
+			return err
+		}
+		if built != nil {
+			built[base.seq] = msg
+		}
+	}
+	gc.sent[conn] = sentState{seq: seq, view: view}
+	conn.Send(msg)
+	return nil
+}
+
+// resync queues the full state conn's next delta will be taken against. The
+// caller must hold gc.mu.
+func (gc *GameConnections) resync(conn *ws.Connection) error {
+	base, sent := gc.sent[conn]
+	if !sent {
+		return nil // its first state is still to come
+	}
+	msg, err := stateMessage(base.view, base.seq)
+	if err != nil {
+		return err
+	}
+	conn.Send(msg)
+	return nil
+}
+
+// Resync sends conn the full state again, for a client that missed a delta
+func (g *Game) Resync(conn *ws.Connection) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.connections.mu.RLock()
+	defer g.connections.mu.RUnlock()
+	return g.connections.resync(conn)
+}
+
+func deltaMessage(base sentState, view GameState, seq uint64) (ws.Message, error) {
+	delta, err := diffState(base.view, view)
+	if err != nil {
+		return ws.Message{}, err
+	}
+	delta.BaseSeq = base.seq
+	payload, err := json.Marshal(delta)
+	if err != nil {
+		return ws.Message{}, err
+	}
+	return ws.Message{Type: ws.MessageTypeStateDelta, Payload: payload, Seq: seq}, nil
+}
+
+func diffState(base, next GameState) (StateDelta, error) {
+	delta := StateDelta{
+		Ply:     plyCount(next.MoveHistory),
+		Squares: diffBoard(base.Board, next.Board),
+		History: diffHistory(base.MoveHistory, next.MoveHistory),
+	}
+	if next.Board != nil && (base.Board == nil || base.Board.WhiteKingPosition != next.Board.WhiteKingPosition) {
+		king := next.Board.WhiteKingPosition
+		delta.WhiteKing = &king
+	}
+	if next.Board != nil && (base.Board == nil || base.Board.BlackKingPosition != next.Board.BlackKingPosition) {
+		king := next.Board.BlackKingPosition
+		delta.BlackKing = &king
+	}
+
+	// the rest is small, so it's compared field by field, and only what
+	// changed is marshalled
+	baseValue, nextValue := reflect.ValueOf(base), reflect.ValueOf(next)
+	for _, field := range deltaFields {
+		value := nextValue.Field(field.index).Interface()
+		// the sound goes with the move, even when it's the same as last time
+		changed := !reflect.DeepEqual(baseValue.Field(field.index).Interface(), value)
+		if !changed && !(field.name == "sound" && delta.Ply != plyCount(base.MoveHistory)) {
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return StateDelta{}, err
+		}
+		if delta.Fields == nil {
+			delta.Fields = make(map[string]json.RawMessage)
+		}
+		delta.Fields[field.name] = data
+	}
+	return delta, nil
+}
+
+// stateField is a GameState field, by its index and its JSON name
+type stateField struct {
+	index int
+	name  string
+}
+
+// deltaFields are the GameState fields a delta carries in Fields. The board
+// and the move history have diffs of their own.
+var deltaFields = func() []stateField {
+	t := reflect.TypeOf(GameState{})
+	fields := []stateField{}
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		switch name {
+		case "-", "boardState", "moveHistory":
+			continue
+		case "":
+			name = t.Field(i).Name
+		}
+		fields = append(fields, stateField{index: i, name: name})
+	}
+	return fields
+}()
+
+// diffBoard lists the squares whose piece differs, with their new piece; a
+// nil piece means the square is now empty
+func diffBoard(base, next *BoardState) []Square {
+	if next == nil {
+		return nil
+	}
+	var squares []Square
+	for y, row := range next.Board {
+		for x, piece := range row {
+			var old *Piece
+			if base != nil && y < len(base.Board) && x < len(base.Board[y]) {
+				old = base.Board[y][x]
+			}
+			if samePiece(old, piece) {
+				continue
+			}
+			squares = append(squares, Square{Position: Position{X: x, Y: y}, Piece: piece})
+		}
+	}
+	return squares
+}
+
+func samePiece(a, b *Piece) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// diffHistory returns the moves from the first one that differs, or nil if
+// the histories are the same
+func diffHistory(base, next []Move) *HistoryDelta {
+	from := 0
+	for from < len(base) && from < len(next) && sameMove(base[from], next[from]) {
+		from++
+	}
+	if from == len(base) && from == len(next) {
+		return nil
+	}
+	return &HistoryDelta{From: from, Moves: append([]Move{}, next[from:]...)}
+}
+
+func sameMove(a, b Move) bool {
+	return samePly(a.WhitePly, b.WhitePly) && samePly(a.BlackPly, b.BlackPly)
+}
+
+func samePly(a, b Ply) bool {
+	return samePiece(a.Piece, b.Piece) && a.From == b.From && a.To == b.To &&
+		samePiece(a.CapturedPiece, b.CapturedPiece) && a.Promotion == b.Promotion && a.Notation == b.Notation
+}
+
+func plyCount(history []Move) int {
+	plies := 0
+	for _, move := range history {
+		if move.WhitePly.Piece != nil {
+			plies++
+		}
+		if move.BlackPly.Piece != nil {
+			plies++
+		}
+	}
+	return plies
+}
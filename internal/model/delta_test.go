@@ -0,0 +1,193 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/benbeisheim/minechess-backend/internal/ws"
+)
+
+// newStartedGame returns a game with "white" and "black" seated
+func newStartedGame(t *testing.T, rules Rules) *Game {
+	t.Helper()
+	g := NewGame("test", TimeControl{Base: 300}, rules)
+	if _, err := g.AddPlayer("white"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.AddPlayer("black"); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func move(fromX, fromY, toX, toY int, mine Position) WSMove {
+	return WSMove{From: Position{X: fromX, Y: fromY}, To: Position{X: toX, Y: toY}, Mine: &mine}
+}
+
+func mustMove(t *testing.T, g *Game, playerID string, m WSMove) {
+	t.Helper()
+	if err := g.MakeMove(playerID, m); err != nil {
+		t.Fatalf("%s %v: %v", playerID, m, err)
+	}
+}
+
+// A delayed spectator view is marshalled after later moves have been made, so
+// it must not see them
+func TestViewIsUnaffectedByLaterMoves(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	mustMove(t, g, "white", move(4, 6, 4, 4, Position{X: 0, Y: 2})) // e4
+	before := g.viewFor("")
+	base := g.viewFor("")
+
+	mustMove(t, g, "black", move(4, 1, 4, 3, Position{X: 0, Y: 5})) // e5
+	if got := plyCount(before.MoveHistory); got != 1 {
+		t.Fatalf("view taken after e4 has %d plies, want 1", got)
+	}
+
+	delta, err := diffState(base, g.viewFor(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delta.History == nil || delta.History.From != 0 || delta.Ply != 2 {
+		t.Fatalf("delta doesn't carry black's reply: %+v", delta.History)
+	}
+}
+
+func TestDeltaRebuildsState(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	moves := []struct {
+		playerID string
+		move     WSMove
+	}{
+		{"white", move(4, 6, 4, 4, Position{X: 0, Y: 2})}, // e4
+		{"black", move(3, 1, 3, 3, Position{X: 0, Y: 5})}, // d5
+		{"white", move(4, 4, 3, 3, Position{X: 7, Y: 2})}, // exd5
+	}
+	prev := g.viewFor("")
+	for _, m := range moves {
+		mustMove(t, g, m.playerID, m.move)
+		next := g.viewFor("")
+		delta, err := diffState(prev, next)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := applyDelta(t, prev, delta), mustJSON(t, next); got != want {
+			t.Fatalf("after %v:\n got %s\nwant %s", m.move, got, want)
+		}
+		prev = next
+	}
+}
+
+// applyDelta does what a client does with a delta, and returns the result as JSON
+func applyDelta(t *testing.T, base GameState, delta StateDelta) string {
+	t.Helper()
+	state := base.clone()
+	board := *state.Board
+	board.Board = make([][]*Piece, len(base.Board.Board))
+	for y, row := range base.Board.Board {
+		board.Board[y] = append([]*Piece{}, row...)
+	}
+	for _, square := range delta.Squares {
+		board.Board[square.Position.Y][square.Position.X] = square.Piece
+	}
+	if delta.WhiteKing != nil {
+		board.WhiteKingPosition = *delta.WhiteKing
+	}
+	if delta.BlackKing != nil {
+		board.BlackKingPosition = *delta.BlackKing
+	}
+	state.Board = &board
+	if delta.History != nil {
+		state.MoveHistory = append(state.MoveHistory[:delta.History.From], delta.History.Moves...)
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal([]byte(mustJSON(t, state)), &fields); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range delta.Fields {
+		fields[name] = value
+	}
+	var rebuilt GameState
+	if err := json.Unmarshal([]byte(mustJSON(t, fields)), &rebuilt); err != nil {
+		t.Fatal(err)
+	}
+	return mustJSON(t, rebuilt)
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestDeltaOnlyCarriesChangedFields(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	base := g.viewFor("")
+	if delta, err := diffState(base, g.viewFor("")); err != nil || delta.Fields != nil {
+		t.Fatalf("delta between equal views: %v, %v", delta.Fields, err)
+	}
+
+	mustMove(t, g, "white", move(4, 6, 4, 4, Position{X: 0, Y: 2})) // e4
+	delta, err := diffState(base, g.viewFor(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"toMove", "fen", "lastMove"} {
+		if _, ok := delta.Fields[name]; !ok {
+			t.Errorf("delta is missing %s", name)
+		}
+	}
+	for _, name := range []string{"rules", "timeControl", "boardState", "moveHistory"} {
+		if value, ok := delta.Fields[name]; ok {
+			t.Errorf("delta carries unchanged %s: %s", name, value)
+		}
+	}
+}
+
+// Spectators sent the same state before are sent the same delta, built once
+func TestSpectatorsShareDeltas(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	first, _ := newConnection(t)
+	second, _ := newConnection(t)
+	g.RegisterSpectator("first", first)
+	g.RegisterSpectator("second", second)
+
+	built := make(map[uint64]ws.Message)
+	view := g.viewFor("")
+	g.seq++
+	g.connections.mu.Lock()
+	defer g.connections.mu.Unlock()
+	for _, conn := range []*ws.Connection{first, second} {
+		if err := g.connections.sendState(conn, view, g.seq, built); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(built) != 1 {
+		t.Errorf("built %d messages for two spectators on the same state, want 1", len(built))
+	}
+}
+
+func BenchmarkBroadcastDelta(b *testing.B) {
+	g := NewGame("test", TimeControl{Base: 300}, Rules{})
+	g.AddPlayer("white")
+	g.AddPlayer("black")
+	if err := g.MakeMove("white", move(4, 6, 4, 4, Position{X: 0, Y: 2})); err != nil {
+		b.Fatal(err)
+	}
+	base := sentState{seq: 1, view: g.viewFor("")}
+	if err := g.MakeMove("black", move(4, 1, 4, 3, Position{X: 0, Y: 5})); err != nil {
+		b.Fatal(err)
+	}
+	next := g.viewFor("")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := deltaMessage(base, next, 2); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorCode is a machine readable reason for rejecting a client command
+type ErrorCode string
+
+const (
+	ErrorCodeNotInGame    ErrorCode = "notInGame"
+	ErrorCodeNotYourTurn  ErrorCode = "notYourTurn"
+	ErrorCodeGameOver     ErrorCode = "gameOver"
+	ErrorCodeNotStarted   ErrorCode = "notStarted"
+	ErrorCodeNoDrawOffer  ErrorCode = "noDrawOffer"
+	ErrorCodeBadRequest   ErrorCode = "badRequest"
+	ErrorCodeInvalidMine  ErrorCode = "invalidMine"
+	ErrorCodeNoDrawClaim  ErrorCode = "noDrawClaim"
+	ErrorCodeCannotAbort  ErrorCode = "cannotAbort"
+	ErrorCodeCannotClaim  ErrorCode = "cannotClaim"
+	ErrorCodeReadOnly     ErrorCode = "readOnly"
+	ErrorCodeIllegalMove  ErrorCode = "illegalMove"
+	ErrorCodeTakebacksOff ErrorCode = "takebacksOff"
+	ErrorCodeNoTakeback   ErrorCode = "noTakeback"
+	ErrorCodeNoRematch    ErrorCode = "noRematch"
+	ErrorCodeDelayed      ErrorCode = "delayed"
+)
+
+// GameError is returned by Game commands that the client is not allowed to make.
+// It is sent back over the socket as the payload of an error message.
+type GameError struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+func (e *GameError) Error() string {
+	return e.Message
+}
+
+// AsGameError returns err as a GameError. Anything the game didn't raise itself
+// is reported as a bad request.
+func AsGameError(err error) *GameError {
+	var gameErr *GameError
+	if !errors.As(err, &gameErr) {
+		gameErr = &GameError{Code: ErrorCodeBadRequest, Message: err.Error()}
+	}
+	return gameErr
+}
+
+func newGameError(code ErrorCode, format string, args ...interface{}) *GameError {
+	return &GameError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+var (
+	ErrNotInGame      = newGameError(ErrorCodeNotInGame, "player is not seated in this game")
+	ErrNotYourTurn    = newGameError(ErrorCodeNotYourTurn, "not your turn")
+	ErrGameOver       = newGameError(ErrorCodeGameOver, "game over")
+	ErrOutOfTime      = newGameError(ErrorCodeGameOver, "out of time")
+	ErrNotStarted     = newGameError(ErrorCodeNotStarted, "game has not started, waiting for an opponent")
+	ErrNoDrawOffer    = newGameError(ErrorCodeNoDrawOffer, "no draw offer to answer")
+	ErrNoDrawClaim    = newGameError(ErrorCodeNoDrawClaim, "no draw can be claimed in this position")
+	ErrReadOnly       = newGameError(ErrorCodeReadOnly, "spectators cannot send commands")
+	ErrCannotAbort    = newGameError(ErrorCodeCannotAbort, "the game can only be aborted before both sides have moved")
+	ErrTakebacksOff   = newGameError(ErrorCodeTakebacksOff, "takebacks are not allowed in this game")
+	ErrNoTakeback     = newGameError(ErrorCodeNoTakeback, "no takeback request to answer")
+	ErrNoRematchOffer = newGameError(ErrorCodeNoRematch, "no rematch offer to accept")
+	ErrDelayed        = newGameError(ErrorCodeDelayed, "this game is shown to spectators with a delay, watch it over the socket")
+)
@@ -8,12 +8,16 @@ import (
 	"time"
 
 	"github.com/benbeisheim/minechess-backend/internal/ws"
+	"github.com/benbeisheim/minechess-backend/pkg/utils/chess"
 	"github.com/gofiber/websocket/v2"
 )
 
 // The connections for a specific game
+// Lock order: Game.mu before GameConnections.mu
 type GameConnections struct {
-	connections map[string]*websocket.Conn // playerID -> connection
+	connections map[string]*ws.Connection // playerID -> connection
+	spectators  map[*ws.Connection]string // connection -> playerID, read only
+	sent        map[*ws.Connection]sentState
 	mu          sync.RWMutex
 }
 
@@ -24,8 +28,27 @@ type Game struct {
 	state       GameState
 	connections *GameConnections // Connections just for this game
 	mine        *Position
+	timeControl TimeControl
 	whiteClock  *Clock
 	blackClock  *Clock
+	flagTimer   *time.Timer // fires when the running clock should run out
+	flagGen     int
+	// firstMoveTimer aborts the game if the side to move misses its first move deadline
+	firstMoveTimer *time.Timer
+	firstMoveGen   int
+	drawOffer      *DrawOffer
+	onCommit       func(GameSnapshot)
+	mines          []*Position // the mine placed with each ply, in order; nil for none
+	startFEN       string      // the position the game started from, if not the standard one
+	positions      []uint64    // hash of the position before the first ply and after each one
+	createdAt      time.Time
+	startedAt      time.Time // when both seats were filled
+	endedAt        time.Time
+	seq            uint64                   // number of the last state broadcast
+	requests       map[string][]moveRequest // playerID -> their most recent tagged moves
+	premoves       map[string]WSMove        // colour -> the move it has queued
+	undo           []undoPoint              // the game before each of the last plies, if takebacks are allowed
+	rematchID      string                   // the agreed rematch, from when it's agreed until it's created
 }
 
 type GameState struct {
@@ -52,6 +75,21 @@ type GameState struct {
 	Explosion                *Position   `json:"explosion"`              // Made nullable
 	WhiteKingAttackedSquares []Position  `json:"whiteKingAttackedSquares"`
 	BlackKingAttackedSquares []Position  `json:"blackKingAttackedSquares"`
+	TimeControl              TimeControl `json:"timeControl"`
+	DrawOffer                *string     `json:"drawOffer"` // colour of the player offering a draw
+	HalfmoveClock            int         `json:"halfmoveClock"`
+	FullmoveNumber           int         `json:"fullmoveNumber"`
+	FEN                      string      `json:"fen"` // the position without the armed mine
+	Rules                    Rules       `json:"rules"`
+	DrawClaim                *string     `json:"drawClaim"` // why a draw can be claimed right now, if it can
+	Status                   GameStatus  `json:"status"`
+	FirstMoveDeadline        *time.Time  `json:"firstMoveDeadline"` // when the game aborts unless the side to move makes their first move
+	SpectatorCount           int         `json:"spectatorCount"`
+	Premove                  *WSMove     `json:"premove"`         // the recipient's own queued premove
+	TakebackRequest          *string     `json:"takebackRequest"` // colour of the player asking to take back their move
+	RematchOffer             *string     `json:"rematchOffer"`    // colour of the player offering a rematch
+	Rematch                  *string     `json:"rematch"`         // ID of the rematch, once it has been created
+	Series                   *Series     `json:"series"`          // the series of rematches this game belongs to, if any
 }
 
 type CapturedPieces struct {
@@ -59,24 +97,57 @@ type CapturedPieces struct {
 	Black []Piece `json:"black"`
 }
 
-func NewGame(id string) *Game {
+func NewGame(id string, timeControl TimeControl, rules Rules) *Game {
 	return &Game{
 		ID:          id,
 		mu:          sync.Mutex{},
-		state:       newGameState(),
+		state:       newGameState(timeControl, rules),
 		connections: NewGameConnections(),
-		whiteClock:  NewClock(time.Duration(1200) * time.Second),
-		blackClock:  NewClock(time.Duration(1200) * time.Second),
+		timeControl: timeControl,
+		whiteClock:  NewClock(timeControl),
+		blackClock:  NewClock(timeControl),
+		positions:   []uint64{chess.StartingPosition().Hash()},
+		createdAt:   time.Now(),
 	}
 }
 
+// NewGameFromFEN starts a game from a custom position. A mine in the seventh
+// FEN field is armed against the side to move, and must be one the rules allow.
+func NewGameFromFEN(id string, fen string, timeControl TimeControl, rules Rules) (*Game, error) {
+	pos, mine, err := chess.ParseFEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	g := NewGame(id, timeControl, rules)
+	if start := pos.FEN(mine); start != chess.StartingFEN {
+		g.startFEN = start
+	}
+	g.setPosition(pos)
+	g.positions = []uint64{pos.Hash()}
+	g.state.WhiteKingAttackedSquares = g.getKingAttackedSquares("white")
+	g.state.BlackKingAttackedSquares = g.getKingAttackedSquares("black")
+	g.state.IsCheck = pos.InCheck(pos.ToMove)
+	if mine != nil {
+		// the mine was armed by the side that just moved, under the same rules
+		armed := positionFromSquare(*mine)
+		if err := rules.validateMine(pos, &armed); err != nil {
+			return nil, err
+		}
+		g.mine = &armed
+	}
+	return g, nil
+}
+
 func NewGameConnections() *GameConnections {
 	return &GameConnections{
-		connections: make(map[string]*websocket.Conn),
+		connections: make(map[string]*ws.Connection),
+		spectators:  make(map[*ws.Connection]string),
+		sent:        make(map[*ws.Connection]sentState),
 	}
 }
 
-func newGameState() GameState {
+func newGameState(timeControl TimeControl, rules Rules) GameState {
+	timeLeft := clientTimeLeft(timeControl.InitialTime())
 	return GameState{
 		Sound:           "",
 		Board:           newBoard(),
@@ -95,12 +166,12 @@ func newGameState() GameState {
 			White: ClientPlayer{
 				ID:       "",
 				Color:    "",
-				TimeLeft: 12000,
+				TimeLeft: timeLeft,
 			},
 			Black: ClientPlayer{
 				ID:       "",
 				Color:    "",
-				TimeLeft: 12000,
+				TimeLeft: timeLeft,
 			},
 		},
 		PromotionSquare:          nil,
@@ -111,6 +182,12 @@ func newGameState() GameState {
 		Explosion:                nil,
 		WhiteKingAttackedSquares: []Position{{X: 3, Y: 7}, {X: 5, Y: 7}, {X: 3, Y: 6}, {X: 4, Y: 6}, {X: 5, Y: 6}},
 		BlackKingAttackedSquares: []Position{{X: 3, Y: 0}, {X: 5, Y: 0}, {X: 3, Y: 1}, {X: 4, Y: 1}, {X: 5, Y: 1}},
+		TimeControl:              timeControl,
+		HalfmoveClock:            0,
+		FullmoveNumber:           1,
+		FEN:                      chess.StartingFEN,
+		Rules:                    rules,
+		Status:                   GameStatusWaiting,
 	}
 }
 
@@ -126,11 +203,25 @@ func (g *Game) AddPlayer(playerID string) (PlayerColor, error) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
+	color, err := g.seatPlayer(playerID)
+	if err != nil {
+		return "", err
+	}
+	g.commit()
+	return color, nil
+}
+
+// seatPlayer gives playerID the first open seat. A player who is already
+// seated keeps their seat.
+func (g *Game) seatPlayer(playerID string) (PlayerColor, error) {
+	if color, ok := g.colorOf(playerID); ok {
+		return PlayerColor(color), nil
+	}
 	if g.state.Players.White.ID == "" {
 		g.state.Players.White = ClientPlayer{
 			ID:       playerID,
 			Color:    "white",
-			TimeLeft: 12000,
+			TimeLeft: clientTimeLeft(g.whiteClock.GetTimeLeft()),
 		}
 		return PlayerColorWhite, nil
 	}
@@ -138,19 +229,26 @@ func (g *Game) AddPlayer(playerID string) (PlayerColor, error) {
 		g.state.Players.Black = ClientPlayer{
 			ID:       playerID,
 			Color:    "black",
-			TimeLeft: 12000,
+			TimeLeft: clientTimeLeft(g.blackClock.GetTimeLeft()),
 		}
+		g.startedAt = time.Now()
+		g.transition(GameStatusInProgress)
+		g.armFirstMoveTimer()
 		return PlayerColorBlack, nil
 	}
 	fmt.Println("Game is full")
 	return "", errors.New("game is full")
 }
 
-func (g *Game) GetState() GameState {
+// GetState returns the state as playerID is allowed to see it
+func (g *Game) GetState(playerID string) (GameState, error) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
-	return g.state
+	if err := g.requireLiveView(playerID); err != nil {
+		return GameState{}, err
+	}
+	return g.viewFor(playerID), nil
 }
 
 func (g *Game) IsPlayerInGame(playerID string) bool {
@@ -176,35 +274,93 @@ func (g *Game) isPlayerInGame(playerID string) bool {
 	return false
 }
 
-func (g *Game) CanSpectate() bool {
+// HasOpenSeat reports whether a player can still join. Spectators use
+// RegisterSpectator instead and can watch any game.
+func (g *Game) HasOpenSeat() bool {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
-	return g.state.Players.White.ID == "" || g.state.Players.Black.ID == ""
+	return g.hasOpenSeat()
 }
 
-func (g *Game) canSpectate() bool {
+func (g *Game) hasOpenSeat() bool {
 	return g.state.Players.White.ID == "" || g.state.Players.Black.ID == ""
 }
 
-func (g *Game) MakeMove(move WSMove) error {
+// colorOf returns the colour playerID is seated as, if any
+func (g *Game) colorOf(playerID string) (string, bool) {
+	switch {
+	case playerID == "":
+		return "", false
+	case g.state.Players.White.ID == playerID:
+		return "white", true
+	case g.state.Players.Black.ID == playerID:
+		return "black", true
+	}
+	return "", false
+}
+
+// authorizeTurn checks that playerID owns the side to move. Every command that
+// acts on behalf of the side to move (moves, mine placement) goes through it.
+func (g *Game) authorizeTurn(playerID string) error {
+	color, ok := g.colorOf(playerID)
+	if !ok {
+		return ErrNotInGame
+	}
+	if color != g.state.ToMove {
+		return ErrNotYourTurn
+	}
+	return nil
+}
+
+func (g *Game) MakeMove(playerID string, move WSMove) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	fmt.Println("Making move in model/game", move)
 
-	if g.state.ToMove != g.state.Board.Board[move.From.Y][move.From.X].Color {
-		return errors.New("not your turn")
+	return g.makeMove(playerID, move)
+}
+
+func (g *Game) makeMove(playerID string, move WSMove) error {
+	fmt.Println("Making move in model/game", playerID, move)
+
+	if err := g.requireInProgress(); err != nil {
+		return err
+	}
+	if err := g.authorizeTurn(playerID); err != nil {
+		return err
+	}
+
+	// the timer may not have fired yet, but a move made after the flag fell doesn't count
+	if g.hasFlagged() {
+		g.flag()
+		g.commit()
+		return ErrOutOfTime
+	}
+
+	if !isValidPosition(move.From) || !isValidPosition(move.To) {
+		return newGameError(ErrorCodeIllegalMove, "invalid move, out of bounds")
 	}
 
 	if g.state.Board.Board[move.From.Y][move.From.X] == nil {
-		return errors.New("no piece at from square")
+		return newGameError(ErrorCodeIllegalMove, "no piece at from square")
+	}
+
+	if g.state.ToMove != g.state.Board.Board[move.From.Y][move.From.X].Color {
+		return newGameError(ErrorCodeNotYourTurn, "piece at from square belongs to %s", g.state.Board.Board[move.From.Y][move.From.X].Color)
 	}
 
 	// Validate and execute the move
 	if err := g.validateMove(move); err != nil {
-		go g.broadcastState()
+		g.broadcastState()
+		return err
+	}
+	if err := g.validateMine(move); err != nil {
 		return err
 	}
+	var undo undoPoint
+	if g.state.Rules.Takebacks {
+		undo = g.undoPoint()
+	}
 	if g.state.ToMove == "white" {
 		g.whiteClock.Stop()
 	} else {
@@ -215,71 +371,235 @@ func (g *Game) MakeMove(move WSMove) error {
 	if err != nil {
 		return err
 	}
+	if g.state.Rules.Takebacks {
+		g.pushUndo(undo)
+	}
+	// a takeback request is for the position it was made in
+	g.setTakebackRequest("")
 	// Start opposing players clock
-	if g.state.ToMove == "white" {
-		g.whiteClock.Start()
-	} else {
-		g.blackClock.Start()
+	if g.state.Status == GameStatusInProgress {
+		g.clockFor(g.state.ToMove).Start()
+		g.armFlagTimer()
 	}
 
+	g.armFirstMoveTimer()
+
 	// update client clock for both players
-	g.state.Players.White.TimeLeft = int(g.whiteClock.timeLeft.Milliseconds() / 100)
-	g.state.Players.Black.TimeLeft = int(g.blackClock.timeLeft.Milliseconds() / 100)
+	g.updateClientClocks()
+	g.commit()
 
+	g.playPremove()
 	return nil
 }
 
-/*
-	func (g *Game) Resign(playerID string) error {
-		g.mu.Lock()
-		defer g.mu.Unlock()
+// DrawOffer is a pending draw offer. It stands until the opponent answers it,
+// the offerer withdraws it, or the opponent makes a move instead.
+type DrawOffer struct {
+	OfferedBy string
+	OfferedAt time.Time
+}
 
-		if !g.IsPlayerInGame(playerID) {
-			return errors.New("player not in game")
-		}
+// finish records the result of the game and stops both clocks. A game that is
+// already over keeps its first result.
+func (g *Game) finish(result string) {
+	if !g.transition(GameStatusFinished) {
+		return
+	}
+	g.stopFirstMoveTimer()
+	g.stopFlagTimer()
+	g.whiteClock.Stop()
+	g.blackClock.Stop()
+	g.updateClientClocks()
+	g.setDrawOffer(nil)
+	g.setDrawClaim("")
+	g.setTakebackRequest("")
+	g.clearPremoves()
+	g.undo = nil
+	if g.state.Series != nil {
+		g.state.Series = g.state.Series.withResult(g.state.Players.White.ID, g.state.Players.Black.ID, result)
+	}
+	g.state.Resolve = &result
+	g.endedAt = time.Now()
+}
+
+func (g *Game) setDrawOffer(offer *DrawOffer) {
+	g.drawOffer = offer
+	g.state.DrawOffer = nil
+	if offer != nil {
+		offeredBy := offer.OfferedBy
+		g.state.DrawOffer = &offeredBy
+	}
+}
+
+// seatedInLiveGame returns the colour of playerID, failing if they aren't
+// seated or the game is already over
+func (g *Game) seatedInLiveGame(playerID string) (string, error) {
+	color, ok := g.colorOf(playerID)
+	if !ok {
+		return "", ErrNotInGame
+	}
+	if err := g.requireInProgress(); err != nil {
+		return "", err
+	}
+	return color, nil
+}
+
+func (g *Game) Resign(playerID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	color, err := g.seatedInLiveGame(playerID)
+	if err != nil {
+		return err
+	}
 
+	g.finish(getOtherColor(color) + " wins by Resignation")
+	g.commit()
+	return nil
+}
+
+func (g *Game) OfferDraw(playerID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	color, err := g.seatedInLiveGame(playerID)
+	if err != nil {
+		return err
+	}
+	if g.drawOffer != nil {
+		if g.drawOffer.OfferedBy == color {
+			return newGameError(ErrorCodeBadRequest, "draw already offered")
+		}
+		// offering a draw while one is pending from the opponent agrees to it
+		g.finish("draw by Agreement")
+		g.commit()
 		return nil
 	}
 
-	func (g *Game) OfferDraw(playerID string) error {
-	    g.mu.Lock()
-	    defer g.mu.Unlock()
+	g.setDrawOffer(&DrawOffer{
+		OfferedBy: color,
+		OfferedAt: time.Now(),
+	})
+	g.commit()
+	return nil
+}
+
+func (g *Game) AcceptDraw(playerID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	color, err := g.seatedInLiveGame(playerID)
+	if err != nil {
+		return err
+	}
+	if g.drawOffer == nil || g.drawOffer.OfferedBy == color {
+		return ErrNoDrawOffer
+	}
+
+	g.finish("draw by Agreement")
+	g.commit()
+	return nil
+}
+
+func (g *Game) DeclineDraw(playerID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	color, err := g.seatedInLiveGame(playerID)
+	if err != nil {
+		return err
+	}
+	if g.drawOffer == nil || g.drawOffer.OfferedBy == color {
+		return ErrNoDrawOffer
+	}
+
+	g.setDrawOffer(nil)
+	g.commit()
+	return nil
+}
 
-	    if !g.IsPlayerInGame(playerID) {
-	        return errors.New("player not in game")
-	    }
+func (g *Game) WithdrawDrawOffer(playerID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 
-	    g.drawOffer = &DrawOffer{
-	        OfferedBy: playerID,
-	        OfferedAt: time.Now(),
-	    }
-	    return nil
+	color, err := g.seatedInLiveGame(playerID)
+	if err != nil {
+		return err
 	}
-*/
+	if g.drawOffer == nil || g.drawOffer.OfferedBy != color {
+		return ErrNoDrawOffer
+	}
+
+	g.setDrawOffer(nil)
+	g.commit()
+	return nil
+}
+
 func (g *Game) validateMove(move WSMove) error {
 	fmt.Println("Validating move in model/game", move)
 	// check if move is out of bounds
 	if move.From.X < 0 || move.From.X > 7 || move.From.Y < 0 || move.From.Y > 7 || move.To.X < 0 || move.To.X > 7 || move.To.Y < 0 || move.To.Y > 7 {
-		return errors.New("invalid move, out of bounds")
+		return newGameError(ErrorCodeIllegalMove, "invalid move, out of bounds")
 	}
-	// check if move is legal
-	moveToCheck := SimpleMove{From: move.From, To: move.To}
-	isLegal := false
-	fmt.Println("Legal moves for piece", g.getLegalMovesForPiece(g.state.Board.Board[move.From.Y][move.From.X]))
-	for _, legalMove := range g.getLegalMovesForPiece(g.state.Board.Board[move.From.Y][move.From.X]) {
-		fmt.Println("Checking legal move", legalMove)
-		if legalMove.From == moveToCheck.From && legalMove.To == moveToCheck.To {
-			isLegal = true
-			break
-		}
+	pos := g.position()
+	if err := pos.ValidatePromotion(move.toChessMove()); err != nil {
+		return newGameError(ErrorCodeIllegalMove, "%s", err)
 	}
-	if !isLegal {
-		return errors.New("invalid move, not legal")
+	// check if move is legal
+	if !pos.IsLegal(move.toChessMove()) {
+		return newGameError(ErrorCodeIllegalMove, "invalid move, not legal")
 	}
 
 	return nil
 }
 
+// position builds the rules engine's view of the current game state
+func (g *Game) position() chess.Position {
+	pos := chess.Position{
+		Board:          g.state.Board.toChessBoard(),
+		ToMove:         chess.Color(g.state.ToMove),
+		HalfmoveClock:  g.state.HalfmoveClock,
+		FullmoveNumber: g.state.FullmoveNumber,
+		Options:        g.state.Rules.chessOptions(),
+	}
+	if g.state.EnPassantTarget != nil {
+		target := g.state.EnPassantTarget.toSquare()
+		pos.EnPassant = &target
+	}
+	return pos
+}
+
+// setPosition copies a position produced by the rules engine back into the game state
+func (g *Game) setPosition(pos chess.Position) {
+	g.state.Board = newBoardFromChess(pos)
+	g.state.ToMove = string(pos.ToMove)
+	g.state.EnPassantTarget = nil
+	if pos.EnPassant != nil {
+		target := positionFromSquare(*pos.EnPassant)
+		g.state.EnPassantTarget = &target
+	}
+	g.state.HalfmoveClock = pos.HalfmoveClock
+	g.state.FullmoveNumber = pos.FullmoveNumber
+	g.state.FEN = pos.FEN(nil)
+}
+
+// validateMine checks the mine placed with a legal move against the game's rules
+func (g *Game) validateMine(move WSMove) error {
+	next, _, err := g.position().Apply(move.toChessMove(), g.mineSquare())
+	if err != nil {
+		return err
+	}
+	return g.state.Rules.validateMine(next, move.Mine)
+}
+
+func (g *Game) mineSquare() *chess.Square {
+	if g.mine == nil {
+		return nil
+	}
+	mine := g.mine.toSquare()
+	return &mine
+}
+
 func (g *Game) executeMove(move WSMove) error {
 	// Initial state validation
 	if g.state.Board == nil {
@@ -301,89 +621,93 @@ func (g *Game) executeMove(move WSMove) error {
 	}
 
 	ply := g.makePly(move)
-	g.state.Sound = "" // clear last turns sounds
+	mover := g.state.ToMove
 
-	// Handle explosion/capture sound logic
-	if g.mine != nil && move.To.X == g.mine.X && move.To.Y == g.mine.Y && piece.Type != Pawn {
-		g.state.Sound = "explosion"
-	} else {
-		targetPiece := g.state.Board.Board[move.To.Y][move.To.X]
-		if targetPiece != nil {
-			g.state.Sound = "capture"
-			switch g.state.ToMove {
+	next, events, err := g.position().Apply(move.toChessMove(), g.mineSquare())
+	if err != nil {
+		return err
+	}
+
+	result := ""
+	g.state.Sound = "move"
+	g.state.Explosion = nil
+	g.state.PromotionSquare = nil
+	g.state.PromotionPiece = nil
+	for _, event := range events {
+		switch event.Type {
+		case chess.EventCapture:
+			if g.state.Sound != "explosion" {
+				g.state.Sound = "capture"
+			}
+			captured := *pieceFromChess(event.Piece, event.Square)
+			switch mover {
 			case "white":
-				g.state.CapturedPieces.White = append(g.state.CapturedPieces.White, *targetPiece)
+				g.state.CapturedPieces.White = append(g.state.CapturedPieces.White, captured)
 			case "black":
-				g.state.CapturedPieces.Black = append(g.state.CapturedPieces.Black, *targetPiece)
+				g.state.CapturedPieces.Black = append(g.state.CapturedPieces.Black, captured)
 			default:
-				return fmt.Errorf("invalid turn state: %s", g.state.ToMove)
+				return fmt.Errorf("invalid turn state: %s", mover)
 			}
-		} else {
-			g.state.Sound = "move"
+		case chess.EventCastle:
+			ply.CastleRookMove = &CastleRookMove{
+				From: positionFromSquare(event.From),
+				To:   positionFromSquare(event.Square),
+			}
+			if event.From.X == 0 {
+				ply.Notation = "O-O-O"
+			} else {
+				ply.Notation = "O-O"
+			}
+		case chess.EventPromotion:
+			square := positionFromSquare(event.Square)
+			promoted := PieceType(event.Piece.Type)
+			g.state.PromotionSquare = &square
+			g.state.PromotionPiece = &promoted
+		case chess.EventExplosion:
+			g.state.Sound = "explosion"
+			if event.Piece.Type == chess.King {
+				continue
+			}
+			explosion := positionFromSquare(event.Square)
+			g.state.Explosion = &explosion
+			// the exploded piece counts as captured by the opponent
+			blown := *pieceFromChess(event.Piece, event.Square)
+			switch mover {
+			case "white":
+				g.state.CapturedPieces.Black = append(g.state.CapturedPieces.Black, blown)
+			case "black":
+				g.state.CapturedPieces.White = append(g.state.CapturedPieces.White, blown)
+			}
+		case chess.EventBombmate:
+			result = getOtherColor(mover) + " wins by Bombmate"
+		case chess.EventCheckmate:
+			result = mover + " wins by Checkmate"
+		case chess.EventStalemate:
+			result = "draw by Stalemate"
 		}
 	}
 
-	// Move the piece
-	g.state.Board.Board[move.From.Y][move.From.X] = nil
-	g.state.Board.Board[move.To.Y][move.To.X] = piece
-
-	// Update piece state
-	piece.HasMoved = true
-	piece.Position = move.To
-
-	// Handle promotion
-	if move.Promotion != "" {
-		piece.Type = move.Promotion
+	g.setPosition(next)
+	if draw := g.recordPosition(next); result == "" {
+		result = draw
 	}
 
-	// Handle special moves based on piece type
-	if piece.Type == King {
-		ply = g.handleCastle(move, ply)
-		switch g.state.ToMove {
-		case "white":
-			g.state.Board.WhiteKingPosition = move.To
-		case "black":
-			g.state.Board.BlackKingPosition = move.To
-		}
-	} else if piece.Type == Pawn {
-		ply = g.handleEnPassant(move, ply)
+	// moving instead of answering a draw offer declines it
+	if g.drawOffer != nil && g.drawOffer.OfferedBy != mover {
+		g.setDrawOffer(nil)
 	}
 
-	// Update move history
-	if g.state.ToMove == "white" {
+	// Update move history. A game set up with black to move starts with an
+	// empty white ply.
+	if mover == "white" {
 		g.state.MoveHistory = append(g.state.MoveHistory, Move{WhitePly: ply})
+	} else if len(g.state.MoveHistory) == 0 {
+		g.state.MoveHistory = append(g.state.MoveHistory, Move{BlackPly: ply})
 	} else {
-		if len(g.state.MoveHistory) == 0 {
-			return fmt.Errorf("invalid move history state: no moves exist for black's turn")
-		}
 		lastIdx := len(g.state.MoveHistory) - 1
 		g.state.MoveHistory[lastIdx].BlackPly = ply
 	}
 
-	// Handle explosion logic
-	if g.mine != nil && move.To.X == g.mine.X && move.To.Y == g.mine.Y && piece.Type != King && piece.Type != Pawn {
-		g.state.Explosion = &move.To
-
-		// Get piece before nullifying for capture list
-		if targetPiece := g.state.Board.Board[move.To.Y][move.To.X]; targetPiece != nil {
-			switch g.state.ToMove {
-			case "white":
-				g.state.CapturedPieces.Black = append(g.state.CapturedPieces.Black, *targetPiece)
-			case "black":
-				g.state.CapturedPieces.White = append(g.state.CapturedPieces.White, *targetPiece)
-			}
-		}
-
-		g.state.Board.Board[move.To.Y][move.To.X] = nil
-
-		if isKingInCheck(g.state.Board, g.state.ToMove) {
-			result := getOtherColor(g.state.ToMove) + " wins by Bombmate"
-			g.state.Resolve = &result
-		}
-	} else {
-		g.state.Explosion = nil
-	}
-
 	// Update king attack squares
 	g.state.WhiteKingAttackedSquares = g.getKingAttackedSquares("white")
 	g.state.BlackKingAttackedSquares = g.getKingAttackedSquares("black")
@@ -393,21 +717,14 @@ func (g *Game) executeMove(move WSMove) error {
 		mineCopy := *g.mine
 		g.state.LastMine = &mineCopy
 	}
-	g.mine = &move.Mine
-
-	// Switch turn and check game state
-	g.switchTurn()
-	g.state.IsCheck = isKingInCheck(g.state.Board, g.state.ToMove)
-
-	if g.isNoLegalMoves(g.state.ToMove) {
-		if g.state.IsCheck {
-			result := getOtherColor(g.state.ToMove) + " wins by Checkmate"
-			g.state.Resolve = &result
-		} else {
-			result := "draw by Stalemate"
-			g.state.Resolve = &result
-		}
+	g.mine = nil
+	if move.Mine != nil {
+		mine := *move.Mine
+		g.mine = &mine
 	}
+	g.mines = append(g.mines, g.mine)
+
+	g.state.IsCheck = next.InCheck(next.ToMove)
 
 	// Update sound if in check
 	if g.state.IsCheck {
@@ -418,7 +735,9 @@ func (g *Game) executeMove(move WSMove) error {
 	lastMove := SimpleMove{From: move.From, To: move.To}
 	g.state.LastMove = &lastMove
 
-	go g.broadcastState()
+	if result != "" {
+		g.finish(result)
+	}
 
 	return nil
 }
@@ -450,394 +769,23 @@ func getOtherColor(color string) string {
 	return "white"
 }
 
-func isKingInCheck(boardState *BoardState, color string) bool {
-	if color == "white" {
-		return isSquareAttacked(boardState, "black", boardState.WhiteKingPosition)
-	}
-	return isSquareAttacked(boardState, "white", boardState.BlackKingPosition)
-}
-
-func isSquareAttacked(boardState *BoardState, attackingColor string, position Position) bool {
-	rookDirs := []Position{{X: 1, Y: 0}, {X: -1, Y: 0}, {X: 0, Y: 1}, {X: 0, Y: -1}}
-	bishopDirs := []Position{{X: 1, Y: 1}, {X: 1, Y: -1}, {X: -1, Y: 1}, {X: -1, Y: -1}}
-	knightDirs := []Position{{X: 2, Y: 1}, {X: 2, Y: -1}, {X: -2, Y: 1}, {X: -2, Y: -1}, {X: 1, Y: 2}, {X: 1, Y: -2}, {X: -1, Y: 2}, {X: -1, Y: -2}}
-	kingDirs := []Position{{X: 1, Y: 0}, {X: -1, Y: 0}, {X: 0, Y: 1}, {X: 0, Y: -1}, {X: 1, Y: 1}, {X: 1, Y: -1}, {X: -1, Y: 1}, {X: -1, Y: -1}}
-	pawnDirs := []Position{{X: -1, Y: 1}, {X: 1, Y: 1}}
-	if attackingColor == "black" {
-		pawnDirs = []Position{{X: -1, Y: -1}, {X: 1, Y: -1}}
-	}
-
-	for _, dir := range rookDirs {
-		targetPos := Position{X: position.X + dir.X, Y: position.Y + dir.Y}
-		for boundaryCheck(targetPos) {
-			if boardState.Board[targetPos.Y][targetPos.X] != nil {
-				if boardState.Board[targetPos.Y][targetPos.X].Color == attackingColor && (boardState.Board[targetPos.Y][targetPos.X].Type == Queen || boardState.Board[targetPos.Y][targetPos.X].Type == Rook) {
-					return true
-				} else {
-					break
-				}
-			}
-			targetPos = Position{X: targetPos.X + dir.X, Y: targetPos.Y + dir.Y}
-		}
-	}
-	for _, dir := range bishopDirs {
-		targetPos := Position{X: position.X + dir.X, Y: position.Y + dir.Y}
-		for boundaryCheck(targetPos) {
-			if boardState.Board[targetPos.Y][targetPos.X] != nil {
-				if boardState.Board[targetPos.Y][targetPos.X].Color == attackingColor && (boardState.Board[targetPos.Y][targetPos.X].Type == Queen || boardState.Board[targetPos.Y][targetPos.X].Type == Bishop) {
-					return true
-				} else {
-					break
-				}
-			}
-			targetPos = Position{X: targetPos.X + dir.X, Y: targetPos.Y + dir.Y}
-		}
-	}
-	for _, dir := range knightDirs {
-		targetPos := Position{X: position.X + dir.X, Y: position.Y + dir.Y}
-		if boundaryCheck(targetPos) && boardState.Board[targetPos.Y][targetPos.X] != nil && boardState.Board[targetPos.Y][targetPos.X].Color == attackingColor && boardState.Board[targetPos.Y][targetPos.X].Type == Knight {
-			return true
-		}
-	}
-	for _, dir := range kingDirs {
-		targetPos := Position{X: position.X + dir.X, Y: position.Y + dir.Y}
-		if boundaryCheck(targetPos) && boardState.Board[targetPos.Y][targetPos.X] != nil && boardState.Board[targetPos.Y][targetPos.X].Color == attackingColor && boardState.Board[targetPos.Y][targetPos.X].Type == King {
-			return true
-		}
-	}
-	for _, dir := range pawnDirs {
-		targetPos := Position{X: position.X + dir.X, Y: position.Y + dir.Y}
-		if boundaryCheck(targetPos) && boardState.Board[targetPos.Y][targetPos.X] != nil && boardState.Board[targetPos.Y][targetPos.X].Color == attackingColor && boardState.Board[targetPos.Y][targetPos.X].Type == Pawn {
-			return true
-		}
-	}
-	return false
-}
-
 func boundaryCheck(position Position) bool {
 	return position.X >= 0 && position.X < 8 && position.Y >= 0 && position.Y < 8
 }
 
-func (g *Game) isNoLegalMoves(color string) bool {
-	return len(g.getLegalMovesForColor(color)) == 0
-}
-
-func (g *Game) getLegalMovesForColor(color string) []SimpleMove {
-	legalMoves := []SimpleMove{}
-	for y := 0; y < 8; y++ {
-		for x := 0; x < 8; x++ {
-			if g.state.Board.Board[y][x] != nil && g.state.Board.Board[y][x].Color == color {
-				legalMoves = append(legalMoves, g.getLegalMovesForPiece(g.state.Board.Board[y][x])...)
-			}
-		}
-	}
-	return legalMoves
-}
-
-func (g *Game) getLegalMovesForPiece(piece *Piece) []SimpleMove {
-	switch piece.Type {
-	case Pawn:
-		psuedoMoves := g.getPsuedoPawnMoves(piece)
-		return g.filterLegalMoves(psuedoMoves)
-	case Knight:
-		psuedoMoves := g.getPsuedoKnightMoves(piece)
-		return g.filterLegalMoves(psuedoMoves)
-	case Bishop:
-		psuedoMoves := g.getPsuedoBishopMoves(piece)
-		return g.filterLegalMoves(psuedoMoves)
-	case Rook:
-		psuedoMoves := g.getPsuedoRookMoves(piece)
-		fmt.Println("Psuedo rook moves", psuedoMoves)
-		return g.filterLegalMoves(psuedoMoves)
-	case Queen:
-		psuedoMoves := g.getPsuedoQueenMoves(piece)
-		return g.filterLegalMoves(psuedoMoves)
-	case King:
-		psuedoMoves := g.getPsuedoKingMoves(piece)
-		fmt.Println("Psuedo king moves", psuedoMoves)
-		return g.filterLegalMoves(psuedoMoves)
-	default:
-		return []SimpleMove{}
-	}
-}
-
-// TempMove represents a move that can be undone
-type TempMove struct {
-	from          Position
-	to            Position
-	movedPiece    *Piece
-	capturedPiece *Piece
-	oldKingPos    Position
-}
-
-func (g *Game) filterLegalMoves(pseudoMoves []SimpleMove) []SimpleMove {
-	fmt.Println("Filtering legal moves: pseudoMoves", pseudoMoves)
-	if len(pseudoMoves) == 0 {
-		return nil
-	}
-
-	legalMoves := make([]SimpleMove, 0, len(pseudoMoves))
-
-	for _, move := range pseudoMoves {
-		if temp, ok := g.tryMove(move); ok {
-			// Check if this move leaves or puts the king in check
-			fmt.Println("Checking if king is in check after move", move)
-			if !isKingInCheck(g.state.Board, g.state.ToMove) {
-				legalMoves = append(legalMoves, move)
-			}
-			g.undoMove(temp)
-		}
-	}
-
-	return legalMoves
-}
-
-// tryMove attempts to make a move and returns data needed to undo it
-func (g *Game) tryMove(move SimpleMove) (TempMove, bool) {
-	temp := TempMove{
-		from:          move.From,
-		to:            move.To,
-		movedPiece:    g.state.Board.Board[move.From.Y][move.From.X],
-		capturedPiece: g.state.Board.Board[move.To.Y][move.To.X],
-	}
-
-	if temp.movedPiece == nil {
-		return TempMove{}, false
-	}
-
-	// Create deep copy of the piece to avoid reference issues
-	movedPieceCopy := *temp.movedPiece
-	movedPieceCopy.Position = move.To
-
-	// Update board state
-	g.state.Board.Board[move.To.Y][move.To.X] = &movedPieceCopy
-	g.state.Board.Board[move.From.Y][move.From.X] = nil
-
-	// Handle king position updates
-	if temp.movedPiece.Type == King {
-		switch g.state.ToMove {
-		case "white":
-			temp.oldKingPos = g.state.Board.WhiteKingPosition
-			g.state.Board.WhiteKingPosition = move.To
-		case "black":
-			temp.oldKingPos = g.state.Board.BlackKingPosition
-			g.state.Board.BlackKingPosition = move.To
-		}
-	}
-
-	return temp, true
-}
-
-// undoMove reverts a move using the saved temporary state
-func (g *Game) undoMove(temp TempMove) {
-	// Restore original board state
-	g.state.Board.Board[temp.from.Y][temp.from.X] = temp.movedPiece
-	g.state.Board.Board[temp.to.Y][temp.to.X] = temp.capturedPiece
-
-	// Restore king position if necessary
-	if temp.movedPiece.Type == King {
-		switch g.state.ToMove {
-		case "white":
-			g.state.Board.WhiteKingPosition = temp.oldKingPos
-			fmt.Println("Restored white king position", g.state.Board.WhiteKingPosition)
-		case "black":
-			g.state.Board.BlackKingPosition = temp.oldKingPos
-			fmt.Println("Restored black king position", g.state.Board.BlackKingPosition)
-		}
-	}
-}
-
-func (g *Game) getPsuedoPawnMoves(piece *Piece) []SimpleMove {
-	pawnMoves := []SimpleMove{}
-	dir := Position{X: 0, Y: -1}
-	enPassantDirs := []Position{{X: 1, Y: -1}, {X: -1, Y: -1}}
-	if piece.Color == "black" {
-		dir = Position{X: 0, Y: 1}
-		enPassantDirs = []Position{{X: 1, Y: 1}, {X: -1, Y: 1}}
-	}
-	// Check move forward 1
-	if g.state.Board.Board[piece.Position.Y+dir.Y][piece.Position.X] == nil {
-		pawnMoves = append(pawnMoves, SimpleMove{From: piece.Position, To: Position{X: piece.Position.X, Y: piece.Position.Y + dir.Y}})
-		// Check move forward 2 if not moved
-		if !piece.HasMoved && g.state.Board.Board[piece.Position.Y+dir.Y*2][piece.Position.X] == nil {
-			pawnMoves = append(pawnMoves, SimpleMove{From: piece.Position, To: Position{X: piece.Position.X, Y: piece.Position.Y + dir.Y*2}})
-		}
-	}
-	// Check capture left
-	if piece.Position.X > 0 && g.state.Board.Board[piece.Position.Y+dir.Y][piece.Position.X-1] != nil && g.state.Board.Board[piece.Position.Y+dir.Y][piece.Position.X-1].Color != piece.Color {
-		pawnMoves = append(pawnMoves, SimpleMove{From: piece.Position, To: Position{X: piece.Position.X - 1, Y: piece.Position.Y + dir.Y}})
-	}
-	// Check capture right
-	if piece.Position.X < 7 && g.state.Board.Board[piece.Position.Y+dir.Y][piece.Position.X+1] != nil && g.state.Board.Board[piece.Position.Y+dir.Y][piece.Position.X+1].Color != piece.Color {
-		pawnMoves = append(pawnMoves, SimpleMove{From: piece.Position, To: Position{X: piece.Position.X + 1, Y: piece.Position.Y + dir.Y}})
-	}
-	// Check en passant
-	for _, dir := range enPassantDirs {
-		if g.state.EnPassantTarget != nil && g.state.EnPassantTarget.X == piece.Position.X+dir.X && g.state.EnPassantTarget.Y == piece.Position.Y+dir.Y {
-			pawnMoves = append(pawnMoves, SimpleMove{From: piece.Position, To: Position{X: piece.Position.X + dir.X, Y: piece.Position.Y + dir.Y}})
-		}
-	}
-	return pawnMoves
-}
-
-func (g *Game) getPsuedoKnightMoves(piece *Piece) []SimpleMove {
-	// TODO: Implement psuedo knight moves
-	knightMoves := []SimpleMove{}
-	knightDirs := []Position{{X: 2, Y: 1}, {X: 2, Y: -1}, {X: -2, Y: 1}, {X: -2, Y: -1}, {X: 1, Y: 2}, {X: 1, Y: -2}, {X: -1, Y: 2}, {X: -1, Y: -2}}
-	for _, dir := range knightDirs {
-		targetPos := Position{X: piece.Position.X + dir.X, Y: piece.Position.Y + dir.Y}
-		if boundaryCheck(targetPos) && (g.state.Board.Board[targetPos.Y][targetPos.X] == nil || g.state.Board.Board[targetPos.Y][targetPos.X].Color != piece.Color) {
-			knightMoves = append(knightMoves, SimpleMove{From: piece.Position, To: targetPos})
-		}
-	}
-	return knightMoves
-}
-
-func (g *Game) getPsuedoBishopMoves(piece *Piece) []SimpleMove {
-	// TODO: Implement psuedo bishop moves
-	bishopMoves := []SimpleMove{}
-	bishopDirs := []Position{{X: 1, Y: 1}, {X: 1, Y: -1}, {X: -1, Y: 1}, {X: -1, Y: -1}}
-	for _, dir := range bishopDirs {
-		targetPos := Position{X: piece.Position.X + dir.X, Y: piece.Position.Y + dir.Y}
-		for boundaryCheck(targetPos) {
-			if g.state.Board.Board[targetPos.Y][targetPos.X] == nil {
-				bishopMoves = append(bishopMoves, SimpleMove{From: piece.Position, To: targetPos})
-			} else if g.state.Board.Board[targetPos.Y][targetPos.X].Color != piece.Color {
-				bishopMoves = append(bishopMoves, SimpleMove{From: piece.Position, To: targetPos})
-				break
-			} else {
-				break
-			}
-			targetPos = Position{X: targetPos.X + dir.X, Y: targetPos.Y + dir.Y}
-		}
-	}
-	return bishopMoves
-}
-
-func (g *Game) getPsuedoRookMoves(piece *Piece) []SimpleMove {
-	// TODO: Implement psuedo rook moves
-	rookMoves := []SimpleMove{}
-	rookDirs := []Position{{X: 1, Y: 0}, {X: -1, Y: 0}, {X: 0, Y: 1}, {X: 0, Y: -1}}
-	fmt.Println("Getting psuedo rook moves for piece in getPsuedoRookMoves", piece)
-	for _, dir := range rookDirs {
-		targetPos := Position{X: piece.Position.X + dir.X, Y: piece.Position.Y + dir.Y}
-		for boundaryCheck(targetPos) {
-			if g.state.Board.Board[targetPos.Y][targetPos.X] == nil {
-				rookMoves = append(rookMoves, SimpleMove{From: piece.Position, To: targetPos})
-			} else if g.state.Board.Board[targetPos.Y][targetPos.X].Color != piece.Color {
-				rookMoves = append(rookMoves, SimpleMove{From: piece.Position, To: targetPos})
-				break
-			} else {
-				break
-			}
-			targetPos = Position{X: targetPos.X + dir.X, Y: targetPos.Y + dir.Y}
-		}
-	}
-	fmt.Println("Rook moves", rookMoves)
-	return rookMoves
-}
-
-func (g *Game) getPsuedoQueenMoves(piece *Piece) []SimpleMove {
-	return append(g.getPsuedoBishopMoves(piece), g.getPsuedoRookMoves(piece)...)
-}
-
-func (g *Game) getPsuedoKingMoves(piece *Piece) []SimpleMove {
-	kingMoves := []SimpleMove{}
-	kingDirs := []Position{{X: 1, Y: 0}, {X: -1, Y: 0}, {X: 0, Y: 1}, {X: 0, Y: -1}, {X: 1, Y: 1}, {X: 1, Y: -1}, {X: -1, Y: 1}, {X: -1, Y: -1}}
-	for _, dir := range kingDirs {
-		targetPos := Position{X: piece.Position.X + dir.X, Y: piece.Position.Y + dir.Y}
-		if boundaryCheck(targetPos) && (g.state.Board.Board[targetPos.Y][targetPos.X] == nil || g.state.Board.Board[targetPos.Y][targetPos.X].Color != piece.Color) {
-			kingMoves = append(kingMoves, SimpleMove{From: piece.Position, To: targetPos})
-		}
-	}
-	if !piece.HasMoved {
-		// check for castle moves
-		if g.state.Board.Board[piece.Position.Y][0] != nil && g.state.Board.Board[piece.Position.Y][0].Type == Rook && !g.state.Board.Board[piece.Position.Y][0].HasMoved {
-			if g.state.Board.Board[piece.Position.Y][1] == nil && g.state.Board.Board[piece.Position.Y][2] == nil && g.state.Board.Board[piece.Position.Y][3] == nil {
-				kingMoves = append(kingMoves, SimpleMove{From: piece.Position, To: Position{X: piece.Position.X - 2, Y: piece.Position.Y}})
-			}
-		}
-		if g.state.Board.Board[piece.Position.Y][7] != nil && g.state.Board.Board[piece.Position.Y][7].Type == Rook && !g.state.Board.Board[piece.Position.Y][7].HasMoved {
-			if g.state.Board.Board[piece.Position.Y][5] == nil && g.state.Board.Board[piece.Position.Y][6] == nil {
-				kingMoves = append(kingMoves, SimpleMove{From: piece.Position, To: Position{X: piece.Position.X + 2, Y: piece.Position.Y}})
-			}
-		}
-	}
-	return kingMoves
-}
-
-func (g *Game) handleEnPassant(move WSMove, ply Ply) Ply {
-	// if the move is an en passant capture, remove the captured piece and alter ply notation
-	if g.state.EnPassantTarget != nil && move.To.X == g.state.EnPassantTarget.X && move.To.Y == g.state.EnPassantTarget.Y {
-		switch g.state.ToMove {
-		case "white":
-			g.state.CapturedPieces.White = append(g.state.CapturedPieces.White, *g.state.Board.Board[move.To.Y+1][move.To.X])
-			g.state.Board.Board[move.To.Y+1][move.To.X] = nil
-		case "black":
-			g.state.CapturedPieces.Black = append(g.state.CapturedPieces.Black, *g.state.Board.Board[move.To.Y-1][move.To.X])
-			g.state.Board.Board[move.To.Y-1][move.To.X] = nil
-		}
-		ply.Notation = "x" + ply.Notation
-	}
-	// if the move is double pawn move, set en passant target
-	switch move.To.Y - move.From.Y {
-	case 2:
-		g.state.EnPassantTarget = &Position{X: move.To.X, Y: move.To.Y - 1}
-	case -2:
-		g.state.EnPassantTarget = &Position{X: move.To.X, Y: move.To.Y + 1}
-	default:
-		g.state.EnPassantTarget = nil
-	}
-
-	return ply
-}
-
-func abs(x int) int {
-	if x < 0 {
-		return -x
-	}
-	return x
-}
-
-func (g *Game) handleCastle(move WSMove, ply Ply) Ply {
-	// assume only called for king move
-	if abs(move.From.X-move.To.X) == 2 {
-		switch move.To.X {
-		case 2:
-			rook := g.state.Board.Board[move.From.Y][0]
-			rook.Position = Position{X: 3, Y: move.From.Y}
-			g.state.Board.Board[move.From.Y][0] = nil
-			g.state.Board.Board[move.From.Y][3] = rook
-			rook.HasMoved = true
-			ply.CastleRookMove = &CastleRookMove{
-				From: Position{X: 0, Y: move.From.Y},
-				To:   Position{X: 3, Y: move.From.Y},
-			}
-			ply.Notation = "O-O-O"
-		case 6:
-			rook := g.state.Board.Board[move.From.Y][7]
-			rook.Position = Position{X: 5, Y: move.From.Y}
-			g.state.Board.Board[move.From.Y][7] = nil
-			g.state.Board.Board[move.From.Y][5] = rook
-			rook.HasMoved = true
-			ply.CastleRookMove = &CastleRookMove{
-				From: Position{X: 7, Y: move.From.Y},
-				To:   Position{X: 5, Y: move.From.Y},
-			}
-			ply.Notation = "O-O"
-		}
-	}
-	return ply
-}
-
 func (g *Game) makePly(move WSMove) Ply {
 	// return ply without rook castle move, add castle rook move in castle detection
-	// at some point, will need to add en passant capture in order to allow for game reconstruction
+	piece := *g.state.Board.Board[move.From.Y][move.From.X]
+	var captured *Piece
+	if target := g.state.Board.Board[move.To.Y][move.To.X]; target != nil {
+		capturedCopy := *target
+		captured = &capturedCopy
+	}
 	return Ply{
-		Piece:          g.state.Board.Board[move.From.Y][move.From.X],
+		Piece:          &piece,
 		From:           move.From,
 		To:             move.To,
-		CapturedPiece:  g.state.Board.Board[move.To.Y][move.To.X],
+		CapturedPiece:  captured,
 		CastleRookMove: nil,
 		Promotion:      move.Promotion,
 		Notation:       g.getNotation(move),
@@ -850,7 +798,8 @@ func (g *Game) getNotation(move WSMove) string {
 	to := move.To
 	pieceNotationPrefix := piece.Type.getPieceNotation()
 	pieceNotationCapture := ""
-	if g.state.Board.Board[to.Y][to.X] != nil {
+	// a diagonal pawn move is always a capture, including en passant
+	if g.state.Board.Board[to.Y][to.X] != nil || (piece.Type == Pawn && from.X != to.X) {
 		pieceNotationCapture = "x"
 	}
 	pieceNotationSuffix := to.getSquareNotation()
@@ -858,103 +807,106 @@ func (g *Game) getNotation(move WSMove) string {
 	if piece.Type == Pawn && from.X != to.X {
 		pawnFileSpecifier = from.getFileNotation()
 	}
+	if move.Promotion != "" {
+		pieceNotationSuffix += "=" + move.Promotion.getPieceNotation()
+	}
 	if g.mine != nil && to.X == g.mine.X && to.Y == g.mine.Y {
 		pieceNotationSuffix += "*"
 	}
 	return fmt.Sprintf("%s%s%s%s", pieceNotationPrefix, pawnFileSpecifier, pieceNotationCapture, pieceNotationSuffix)
 }
 
-func (g *Game) switchTurn() {
-	if g.state.ToMove == "white" {
-		g.state.ToMove = "black"
-	} else {
-		g.state.ToMove = "white"
-	}
-}
-
-func (g *Game) RegisterConnection(playerID string, conn *websocket.Conn) error {
-	connID := fmt.Sprintf("%p", conn)
-	fmt.Printf("Starting RegisterConnection for player %s, conn %s\n", playerID, connID)
+func (g *Game) RegisterConnection(playerID string, conn *ws.Connection) error {
+	fmt.Printf("Starting RegisterConnection for player %s, conn %p\n", playerID, conn)
 
 	g.mu.Lock()
-	isAuthorized := g.isPlayerInGame(playerID) || g.canSpectate()
-	g.mu.Unlock()
+	defer g.mu.Unlock()
 
-	if !isAuthorized {
-		return errors.New("not authorized to join this game")
+	// only players get the live game; connecting while a seat is open takes it,
+	// and anyone else has to watch as a spectator
+	if !g.isPlayerInGame(playerID) {
+		if !g.hasOpenSeat() {
+			return ErrNotInGame
+		}
+		if _, err := g.seatPlayer(playerID); err != nil {
+			return err
+		}
 	}
 
+	// A new connection takes over from an existing one, which is most likely a
+	// refreshed tab or a connection that hasn't timed out yet
 	g.connections.mu.Lock()
-	if _, exists := g.connections.connections[playerID]; exists {
-		// If already connected, reject new connection
-		g.connections.mu.Unlock()
-		conn.WriteMessage(
-			websocket.CloseMessage,
-			websocket.FormatCloseMessage(
-				websocket.CloseNormalClosure,
-				"Connection already exists",
-			),
-		)
-		conn.Close()
-		return nil
-	}
-
-	// Register new connection
+	old, exists := g.connections.connections[playerID]
 	g.connections.connections[playerID] = conn
+	delete(g.connections.sent, old)
 	g.connections.mu.Unlock()
-	fmt.Printf("Registered new connection %s for player %s\n", connID, playerID)
+	fmt.Printf("Registered new connection %p for player %s\n", conn, playerID)
 
-	// Send initial state...
-	go g.broadcastState()
+	if exists && old != conn {
+		fmt.Printf("Closing superseded connection %p for player %s\n", old, playerID)
+		old.Close(websocket.CloseNormalClosure, "superseded")
+	}
+
+	eventType, event := g.setConnected(playerID, true)
+	if event != nil {
+		g.broadcastEvent(eventType, event)
+	}
+	g.commit() // also sends the initial state
 	return nil
 }
 
-func (g *Game) UnregisterConnection(playerID string) {
+// UnregisterConnection removes conn when its socket closes. A connection that
+// has already been superseded leaves the player's current one in place.
+func (g *Game) UnregisterConnection(playerID string, conn *ws.Connection) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
 	g.connections.mu.Lock()
-	defer g.connections.mu.Unlock()
+	current, exists := g.connections.connections[playerID]
+	// Only unregister if this is still the current connection
+	removed := exists && current == conn
+	if removed {
+		fmt.Printf("Unregistering current connection %p for player %s\n", conn, playerID)
+		delete(g.connections.connections, playerID)
+		delete(g.connections.sent, conn)
+	} else {
+		fmt.Printf("Ignoring unregister for old connection %p for player %s\n", conn, playerID)
+	}
+	g.connections.mu.Unlock()
+	if !removed {
+		return
+	}
 
-	if conn, exists := g.connections.connections[playerID]; exists {
-		connID := fmt.Sprintf("%p", conn)
-		// Only unregister if this is still the current connection
-		if fmt.Sprintf("%p", g.connections.connections[playerID]) == connID {
-			fmt.Printf("Unregistering current connection %s for player %s\n", connID, playerID)
-			delete(g.connections.connections, playerID)
-		} else {
-			fmt.Printf("Ignoring unregister for old connection %s for player %s\n", connID, playerID)
-		}
+	eventType, event := g.setConnected(playerID, false)
+	if event != nil {
+		g.broadcastEvent(eventType, event)
 	}
+	g.commit()
 }
 
-func (g *Game) broadcastState() error {
-	// Get a snapshot of connections under the connections mutex
-	g.connections.mu.RLock()
-	defer g.connections.mu.RUnlock()
-	// Make a copy of the connections we need to broadcast to
-	activeConnections := make(map[string]*websocket.Conn)
-	for playerID, conn := range g.connections.connections {
-		activeConnections[playerID] = conn
-	}
+// broadcastState queues each connection's view of the state, or what changed
+// in it. It's called with g.mu held, so every view is taken from the same
+// state, and the sequence number lets clients tell a newer state from an older
+// one.
+func (g *Game) broadcastState() {
+	g.seq++
 
-	// Broadcast to each connection without holding any locks
-	for playerID, conn := range activeConnections {
-		fmt.Println("Broadcasting state to player", playerID, g.state)
-		jsonGameState, err := json.Marshal(g.state)
-		if err != nil {
+	g.connections.mu.Lock()
+	defer g.connections.mu.Unlock()
+	for playerID, conn := range g.connections.connections {
+		if err := g.connections.sendState(conn, g.viewFor(playerID), g.seq, nil); err != nil {
 			fmt.Println("Failed to marshal state to JSON", err)
-			continue
 		}
+	}
+	if len(g.connections.spectators) > 0 {
+		g.broadcastToSpectators(g.viewFor(""), g.state.Rules.spectatorDelay())
+	}
+}
 
-		if err := conn.WriteJSON(ws.Message{
-			Type:    ws.MessageTypeGameState,
-			Payload: json.RawMessage(jsonGameState),
-		}); err != nil {
-			fmt.Println("Failed to send state to player", playerID, err)
-			g.connections.mu.Lock()
-			delete(g.connections.connections, playerID)
-			g.connections.mu.Unlock()
-			continue
-		}
-		fmt.Println("Sent state to player", playerID)
+func stateMessage(view GameState, seq uint64) (ws.Message, error) {
+	payload, err := json.Marshal(view)
+	if err != nil {
+		return ws.Message{}, err
 	}
-	return nil
+	return ws.Message{Type: ws.MessageTypeGameState, Payload: payload, Seq: seq}, nil
 }
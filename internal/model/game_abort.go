@@ -0,0 +1,94 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// hasBothMoved reports whether each side has made a move. Until then the game
+// can be aborted, and one of the sides is racing its first move deadline.
+// Every ply records a mine, even if it's nil, so that's the ply count.
+func (g *Game) hasBothMoved() bool {
+	return len(g.mines) >= 2
+}
+
+// armFirstMoveTimer starts the first move deadline of the side to move, if
+// they have yet to make their first move
+func (g *Game) armFirstMoveTimer() {
+	g.stopFirstMoveTimer()
+	if g.state.Status != GameStatusInProgress || g.hasBothMoved() {
+		return
+	}
+	wait := g.timeControl.FirstMoveDeadline(g.state.ToMove)
+	deadline := time.Now().Add(wait)
+	g.state.FirstMoveDeadline = &deadline
+	gen := g.firstMoveGen
+	g.firstMoveTimer = time.AfterFunc(wait, func() {
+		g.checkFirstMove(gen)
+	})
+}
+
+// stopFirstMoveTimer cancels the deadline. Bumping the generation turns a
+// timer that has already fired into a no-op.
+func (g *Game) stopFirstMoveTimer() {
+	if g.firstMoveTimer != nil {
+		g.firstMoveTimer.Stop()
+		g.firstMoveTimer = nil
+	}
+	g.firstMoveGen++
+	g.state.FirstMoveDeadline = nil
+}
+
+func (g *Game) checkFirstMove(gen int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if gen != g.firstMoveGen || g.state.Status != GameStatusInProgress {
+		return
+	}
+	fmt.Println("First move deadline passed in game", g.ID, g.state.ToMove)
+	g.abort("aborted, " + g.state.ToMove + " did not move")
+	g.commit()
+}
+
+// abort ends the game without a result. Aborted games aren't archived, so
+// they count for neither player.
+func (g *Game) abort(reason string) {
+	if !g.transition(GameStatusAborted) {
+		return
+	}
+	g.stopFirstMoveTimer()
+	g.stopFlagTimer()
+	g.whiteClock.Stop()
+	g.blackClock.Stop()
+	g.updateClientClocks()
+	g.setDrawOffer(nil)
+	g.setDrawClaim("")
+	g.setTakebackRequest("")
+	g.clearPremoves()
+	g.undo = nil
+	g.state.Resolve = &reason
+	g.state.Sound = ""
+	g.endedAt = time.Now()
+}
+
+// Abort lets a seated player call the game off before both sides have moved
+func (g *Game) Abort(playerID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	color, ok := g.colorOf(playerID)
+	if !ok {
+		return ErrNotInGame
+	}
+	if g.state.Status.IsOver() {
+		return ErrGameOver
+	}
+	if g.hasBothMoved() {
+		return ErrCannotAbort
+	}
+
+	g.abort("aborted by " + color)
+	g.commit()
+	return nil
+}
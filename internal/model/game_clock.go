@@ -0,0 +1,82 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/benbeisheim/minechess-backend/pkg/utils/chess"
+)
+
+func (g *Game) clockFor(color string) *Clock {
+	if color == "white" {
+		return g.whiteClock
+	}
+	return g.blackClock
+}
+
+// updateClientClocks copies both clocks into the state in deciseconds
+func (g *Game) updateClientClocks() {
+	g.state.Players.White.TimeLeft = clientTimeLeft(g.whiteClock.GetTimeLeft())
+	g.state.Players.Black.TimeLeft = clientTimeLeft(g.blackClock.GetTimeLeft())
+}
+
+func clientTimeLeft(d time.Duration) int {
+	if d < 0 {
+		return 0
+	}
+	return int(d.Milliseconds() / 100)
+}
+
+// armFlagTimer schedules a flag check for when the clock of the side to move
+// runs out. Re-arming bumps flagGen so that any older timer becomes a no-op.
+func (g *Game) armFlagTimer() {
+	if g.flagTimer != nil {
+		g.flagTimer.Stop()
+	}
+	g.flagGen++
+	gen := g.flagGen
+	g.flagTimer = time.AfterFunc(g.clockFor(g.state.ToMove).TimeUntilFlag(), func() {
+		g.checkFlag(gen)
+	})
+}
+
+func (g *Game) stopFlagTimer() {
+	if g.flagTimer != nil {
+		g.flagTimer.Stop()
+	}
+	g.flagGen++
+}
+
+func (g *Game) checkFlag(gen int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if gen != g.flagGen || g.state.Status != GameStatusInProgress {
+		return
+	}
+	if remaining := g.clockFor(g.state.ToMove).TimeUntilFlag(); remaining > 0 {
+		// timers can fire slightly early, try again when the clock really runs out
+		g.armFlagTimer()
+		return
+	}
+	g.flag()
+	g.commit()
+}
+
+// hasFlagged reports whether the side to move is out of time
+func (g *Game) hasFlagged() bool {
+	return g.state.Status == GameStatusInProgress && g.clockFor(g.state.ToMove).GetTimeLeft() <= 0
+}
+
+// flag ends the game on time against the side to move. It's a draw instead
+// when the opponent couldn't have won anyway.
+func (g *Game) flag() {
+	winner := getOtherColor(g.state.ToMove)
+	result := winner + " wins on time"
+	if chess.InsufficientMaterial(g.position(), chess.Color(winner)) {
+		result = "draw by Timeout vs Insufficient material"
+	}
+	fmt.Println("Flag fell in game", g.ID, result)
+	g.finish(result)
+	g.state.Sound = ""
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/benbeisheim/minechess-backend/internal/ws"
+)
+
+// runOut empties color's clock. With arm set the flag timer is re-armed, so it
+// fires straight away, as it would when the clock really runs out.
+func runOut(g *Game, color string, arm bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	clock := g.clockFor(color)
+	clock.mu.Lock()
+	clock.timeLeft = 0
+	clock.mu.Unlock()
+	if arm {
+		g.armFlagTimer()
+	}
+}
+
+func TestFlagFallEndsGame(t *testing.T) {
+	tests := []struct {
+		name   string
+		fen    string
+		result string
+	}{
+		{"opponent can win", "4k3/8/8/8/8/8/3q4/4K3 w - - 0 1 -", "black wins on time"},
+		{"opponent has a lone king", "4k3/8/8/8/8/8/3Q4/4K3 w - - 0 1 -", "draw by Timeout vs Insufficient material"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := NewGameFromFEN("test", tt.fen, TimeControl{Base: 300}, Rules{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn, socket := newConnection(t)
+			for _, playerID := range []string{"white", "black"} {
+				if _, err := g.AddPlayer(playerID); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := g.RegisterConnection("black", conn); err != nil {
+				t.Fatal(err)
+			}
+			runOut(g, "white", true)
+
+			socket.waitFor(t, "the result to be broadcast", func(written []ws.Message) bool {
+				return len(written) > 0 && strings.Contains(string(written[len(written)-1].Payload), tt.result)
+			})
+			snapshot := g.Snapshot()
+			if snapshot.State.Status != GameStatusFinished || snapshot.State.Resolve == nil || *snapshot.State.Resolve != tt.result {
+				t.Fatalf("status %s, result %v, want %q", snapshot.State.Status, snapshot.State.Resolve, tt.result)
+			}
+			if snapshot.WhiteClock.IsRunning || snapshot.BlackClock.IsRunning {
+				t.Error("a clock is still running after the flag fell")
+			}
+		})
+	}
+}
+
+// A move that reaches the game before the flag timer has fired is too late
+func TestMoveAfterFlagFallIsRejected(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	mustMove(t, g, "white", move(4, 6, 4, 4, a6))                   // e4
+	mustMove(t, g, "black", move(4, 1, 4, 3, Position{X: 0, Y: 5})) // e5
+	runOut(g, "white", false)
+
+	if err := g.MakeMove("white", move(6, 7, 5, 5, Position{X: 7, Y: 2})); !errors.Is(err, ErrOutOfTime) { // Nf3
+		t.Fatalf("got %v, want %v", err, ErrOutOfTime)
+	}
+	state, _ := g.GetState("white")
+	if state.Resolve == nil || *state.Resolve != "black wins on time" {
+		t.Fatalf("result %v, want black to win on time", state.Resolve)
+	}
+	if state.Board.Board[5][5] != nil {
+		t.Error("the late move was played")
+	}
+}
@@ -0,0 +1,77 @@
+package model
+
+import "github.com/benbeisheim/minechess-backend/pkg/utils/chess"
+
+const (
+	claimableRepetitions = 3   // threefold repetition may be claimed
+	automaticRepetitions = 5   // fivefold repetition ends the game
+	claimableHalfmoves   = 100 // the fifty-move rule may be claimed
+	automaticHalfmoves   = 150 // the 75-move rule ends the game
+)
+
+// recordPosition adds pos to the repetition history and returns the result of
+// a draw that ends the game without being claimed, if there is one
+func (g *Game) recordPosition(pos chess.Position) string {
+	hash := pos.Hash()
+	g.positions = append(g.positions, hash)
+	g.setDrawClaim(g.drawClaim())
+
+	switch {
+	case g.repetitions(hash) >= automaticRepetitions:
+		return "draw by Fivefold repetition"
+	case pos.HalfmoveClock >= automaticHalfmoves:
+		return "draw by 75-move rule"
+	case pos.IsDeadPosition():
+		return "draw by Insufficient material"
+	}
+	return ""
+}
+
+// repetitions counts how often the position with the given hash has occurred
+func (g *Game) repetitions(hash uint64) int {
+	count := 0
+	for _, seen := range g.positions {
+		if seen == hash {
+			count++
+		}
+	}
+	return count
+}
+
+// drawClaim returns the reason either player may claim a draw in the current
+// position, or "" if they can't
+func (g *Game) drawClaim() string {
+	if len(g.positions) > 0 && g.repetitions(g.positions[len(g.positions)-1]) >= claimableRepetitions {
+		return "Threefold repetition"
+	}
+	if g.state.HalfmoveClock >= claimableHalfmoves {
+		return "Fifty-move rule"
+	}
+	return ""
+}
+
+func (g *Game) setDrawClaim(reason string) {
+	g.state.DrawClaim = nil
+	if reason != "" {
+		g.state.DrawClaim = &reason
+	}
+}
+
+// ClaimDraw ends the game in a draw if the position has occurred three times
+// or fifty moves have passed without a pawn move, capture or explosion
+func (g *Game) ClaimDraw(playerID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, err := g.seatedInLiveGame(playerID); err != nil {
+		return err
+	}
+	reason := g.drawClaim()
+	if reason == "" {
+		return ErrNoDrawClaim
+	}
+
+	g.finish("draw by " + reason)
+	g.commit()
+	return nil
+}
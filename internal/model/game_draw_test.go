@@ -0,0 +1,106 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func resultOf(t *testing.T, g *Game) string {
+	t.Helper()
+	state, err := g.GetState("white")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Resolve == nil {
+		return ""
+	}
+	return *state.Resolve
+}
+
+func TestResign(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	if err := g.Resign("black"); err != nil {
+		t.Fatal(err)
+	}
+	if got := resultOf(t, g); got != "white wins by Resignation" {
+		t.Errorf("result %q, want white to win by resignation", got)
+	}
+	if err := g.Resign("white"); !errors.Is(err, ErrGameOver) {
+		t.Errorf("resigning a finished game: got %v, want %v", err, ErrGameOver)
+	}
+}
+
+func TestDrawOffer(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer func(g *Game) error
+		result string
+	}{
+		{"accepted", func(g *Game) error { return g.AcceptDraw("black") }, "draw by Agreement"},
+		{"offered back", func(g *Game) error { return g.OfferDraw("black") }, "draw by Agreement"},
+		{"declined", func(g *Game) error { return g.DeclineDraw("black") }, ""},
+		{"withdrawn", func(g *Game) error { return g.WithdrawDrawOffer("white") }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newStartedGame(t, Rules{})
+			if err := g.OfferDraw("white"); err != nil {
+				t.Fatal(err)
+			}
+			if state, _ := g.GetState("black"); state.DrawOffer == nil || *state.DrawOffer != "white" {
+				t.Fatalf("draw offer %v, want white's", state.DrawOffer)
+			}
+			if err := tt.answer(g); err != nil {
+				t.Fatal(err)
+			}
+			if got := resultOf(t, g); got != tt.result {
+				t.Errorf("result %q, want %q", got, tt.result)
+			}
+			if state, _ := g.GetState("black"); state.DrawOffer != nil {
+				t.Errorf("draw offer %v still stands", *state.DrawOffer)
+			}
+		})
+	}
+}
+
+// Only the opponent can answer an offer, and only its offerer can withdraw it
+func TestDrawOfferAnswersNeedAnOffer(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	if err := g.AcceptDraw("black"); !errors.Is(err, ErrNoDrawOffer) {
+		t.Errorf("accepting without an offer: got %v, want %v", err, ErrNoDrawOffer)
+	}
+	if err := g.OfferDraw("white"); err != nil {
+		t.Fatal(err)
+	}
+	for name, answer := range map[string]func(string) error{"accept": g.AcceptDraw, "decline": g.DeclineDraw} {
+		if err := answer("white"); !errors.Is(err, ErrNoDrawOffer) {
+			t.Errorf("%s own offer: got %v, want %v", name, err, ErrNoDrawOffer)
+		}
+	}
+	if err := g.WithdrawDrawOffer("black"); !errors.Is(err, ErrNoDrawOffer) {
+		t.Errorf("withdrawing the opponent's offer: got %v, want %v", err, ErrNoDrawOffer)
+	}
+	if err := g.OfferDraw("white"); err == nil {
+		t.Error("the same offer was made twice")
+	}
+}
+
+// Moving instead of answering declines the offer, while the offerer's own
+// move leaves it standing
+func TestDrawOfferIsClearedByOpponentsMove(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	if err := g.OfferDraw("white"); err != nil {
+		t.Fatal(err)
+	}
+	mustMove(t, g, "white", move(4, 6, 4, 4, a6)) // e4
+	if state, _ := g.GetState("black"); state.DrawOffer == nil {
+		t.Fatal("the offerer's move cleared the offer")
+	}
+	mustMove(t, g, "black", move(4, 1, 4, 3, Position{X: 0, Y: 5})) // e5
+	if state, _ := g.GetState("black"); state.DrawOffer != nil {
+		t.Error("the offer stands after the opponent moved")
+	}
+	if err := g.AcceptDraw("black"); !errors.Is(err, ErrNoDrawOffer) {
+		t.Errorf("got %v, want %v", err, ErrNoDrawOffer)
+	}
+}
@@ -0,0 +1,125 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/benbeisheim/minechess-backend/internal/ws"
+)
+
+// A premove is a move queued by the side not to move, to be played as soon as
+// the opponent has moved. Whether it's legal can only be known then, so it's
+// checked like any other move at that point and dropped if it fails. Only its
+// owner is told about it; to everyone else it doesn't exist until it's played.
+
+// PremoveStatus is what became of a premove
+type PremoveStatus string
+
+const (
+	PremoveQueued    PremoveStatus = "queued"
+	PremovePlayed    PremoveStatus = "played"
+	PremoveCancelled PremoveStatus = "cancelled"
+	PremoveRejected  PremoveStatus = "rejected"
+)
+
+// PremoveEvent is the payload of a premoveStatus message
+type PremoveEvent struct {
+	Status PremoveStatus `json:"status"`
+	Move   WSMove        `json:"move"`
+	Reason string        `json:"reason,omitempty"`
+}
+
+// Premove queues move for the player, replacing any premove they already had.
+// On the player's own turn it's simply made.
+func (g *Game) Premove(playerID string, move WSMove) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	color, err := g.seatedInLiveGame(playerID)
+	if err != nil {
+		return err
+	}
+	if color == g.state.ToMove {
+		return g.makeMove(playerID, move)
+	}
+	if !isValidPosition(move.From) || !isValidPosition(move.To) {
+		return newGameError(ErrorCodeIllegalMove, "invalid move, out of bounds")
+	}
+	if piece := g.state.Board.Board[move.From.Y][move.From.X]; piece == nil || piece.Color != color {
+		return newGameError(ErrorCodeIllegalMove, "no piece of yours at from square")
+	}
+
+	if g.premoves == nil {
+		g.premoves = make(map[string]WSMove)
+	}
+	g.premoves[color] = move
+	g.sendPremoveStatus(playerID, PremoveEvent{Status: PremoveQueued, Move: move})
+	g.commit()
+	return nil
+}
+
+// CancelPremove drops the player's premove, if they have one
+func (g *Game) CancelPremove(playerID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	color, ok := g.colorOf(playerID)
+	if !ok {
+		return ErrNotInGame
+	}
+	move, queued := g.premoves[color]
+	if !queued {
+		return nil
+	}
+	delete(g.premoves, color)
+	g.sendPremoveStatus(playerID, PremoveEvent{Status: PremoveCancelled, Move: move})
+	g.commit()
+	return nil
+}
+
+// playPremove plays the side to move's premove, if they queued one. It's
+// called with g.mu held, right after the opponent's move has been committed.
+func (g *Game) playPremove() {
+	color := g.state.ToMove
+	move, queued := g.premoves[color]
+	if !queued {
+		return
+	}
+	delete(g.premoves, color)
+	playerID := g.seat(color).ID
+
+	// LastMine was revealed by the opponent's move, after this was queued, so
+	// a premove onto it wasn't made knowing the square; it's cancelled rather
+	// than played blind
+	if g.state.LastMine != nil && move.To == *g.state.LastMine {
+		g.sendPremoveStatus(playerID, PremoveEvent{Status: PremoveCancelled, Move: move, Reason: "moves onto the revealed mine"})
+		g.commit()
+		return
+	}
+	if err := g.makeMove(playerID, move); err != nil {
+		g.sendPremoveStatus(playerID, PremoveEvent{Status: PremoveRejected, Move: move, Reason: err.Error()})
+		g.commit()
+		return
+	}
+	g.sendPremoveStatus(playerID, PremoveEvent{Status: PremovePlayed, Move: move})
+}
+
+// clearPremoves drops every premove once the game is over
+func (g *Game) clearPremoves() {
+	g.premoves = nil
+}
+
+// sendPremoveStatus tells only the premove's owner what became of it
+func (g *Game) sendPremoveStatus(playerID string, event PremoveEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		fmt.Println("Failed to marshal premove status", err)
+		return
+	}
+
+	g.connections.mu.RLock()
+	defer g.connections.mu.RUnlock()
+	if conn, ok := g.connections.connections[playerID]; ok {
+		conn.Send(ws.Message{Type: ws.MessageTypePremoveStatus, Payload: data})
+	}
+}
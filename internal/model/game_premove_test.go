@@ -0,0 +1,106 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/benbeisheim/minechess-backend/internal/ws"
+)
+
+// lastPremoveStatus waits for the last premove status sent to the socket to
+// be want, and returns it
+func lastPremoveStatus(t *testing.T, socket *fakeSocket, want PremoveStatus) PremoveEvent {
+	t.Helper()
+	var last PremoveEvent
+	socket.waitFor(t, "premove "+string(want), func(written []ws.Message) bool {
+		for _, msg := range written {
+			if msg.Type == ws.MessageTypePremoveStatus {
+				if err := json.Unmarshal(msg.Payload, &last); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		return last.Status == want
+	})
+	return last
+}
+
+// newPremoveGame returns a game after e4 with white's socket connected, so
+// white can premove while black is to move
+func newPremoveGame(t *testing.T) (*Game, *fakeSocket) {
+	t.Helper()
+	g := newStartedGame(t, Rules{})
+	conn, socket := newConnection(t)
+	if err := g.RegisterConnection("white", conn); err != nil {
+		t.Fatal(err)
+	}
+	mustMove(t, g, "white", move(4, 6, 4, 4, a6)) // e4, arming a6
+	return g, socket
+}
+
+func TestPremoveIsPlayedAfterOpponentMoves(t *testing.T) {
+	g, socket := newPremoveGame(t)
+	if err := g.Premove("white", move(3, 6, 3, 4, Position{X: 7, Y: 2})); err != nil { // d4
+		t.Fatal(err)
+	}
+	if state, _ := g.GetState("black"); state.Premove != nil {
+		t.Error("the opponent sees the premove")
+	}
+	mustMove(t, g, "black", move(3, 1, 3, 3, Position{X: 7, Y: 5})) // d5
+
+	state, _ := g.GetState("white")
+	if piece := state.Board.Board[4][3]; piece == nil || piece.Type != Pawn || state.ToMove != "black" {
+		t.Fatalf("d4 = %+v with %s to move, want the premoved pawn with black to move", piece, state.ToMove)
+	}
+	lastPremoveStatus(t, socket, PremovePlayed)
+}
+
+// The mine white armed with e4 is revealed when black moves, after the
+// premove onto it was queued, so the premove is cancelled rather than played
+func TestPremoveOntoRevealedMineIsCancelled(t *testing.T) {
+	g, socket := newPremoveGame(t)
+	if err := g.Premove("white", move(5, 7, 0, 2, Position{X: 7, Y: 2})); err != nil { // Ba6
+		t.Fatal(err)
+	}
+	mustMove(t, g, "black", move(3, 1, 3, 3, Position{X: 7, Y: 5})) // d5
+
+	state, _ := g.GetState("white")
+	if state.LastMine == nil || *state.LastMine != a6 {
+		t.Fatalf("last mine = %v, want %v", state.LastMine, a6)
+	}
+	if state.ToMove != "white" || state.Board.Board[a6.Y][a6.X] != nil {
+		t.Fatalf("the premove onto the revealed mine was played")
+	}
+	if event := lastPremoveStatus(t, socket, PremoveCancelled); event.Reason == "" {
+		t.Error("cancelled without a reason")
+	}
+}
+
+func TestIllegalPremoveIsRejected(t *testing.T) {
+	g, socket := newPremoveGame(t)
+	if err := g.Premove("white", move(4, 4, 4, 3, Position{X: 7, Y: 2})); err != nil { // e5
+		t.Fatal(err)
+	}
+	mustMove(t, g, "black", move(4, 1, 4, 3, Position{X: 7, Y: 5})) // e5, blocking it
+
+	if state, _ := g.GetState("white"); state.ToMove != "white" {
+		t.Fatal("the blocked premove was played")
+	}
+	lastPremoveStatus(t, socket, PremoveRejected)
+}
+
+func TestCancelPremove(t *testing.T) {
+	g, socket := newPremoveGame(t)
+	if err := g.Premove("white", move(3, 6, 3, 4, Position{X: 7, Y: 2})); err != nil { // d4
+		t.Fatal(err)
+	}
+	if err := g.CancelPremove("white"); err != nil {
+		t.Fatal(err)
+	}
+	lastPremoveStatus(t, socket, PremoveCancelled)
+	mustMove(t, g, "black", move(3, 1, 3, 3, Position{X: 7, Y: 5})) // d5
+
+	if state, _ := g.GetState("white"); state.ToMove != "white" || state.Premove != nil {
+		t.Error("the cancelled premove was played")
+	}
+}
@@ -0,0 +1,127 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/benbeisheim/minechess-backend/internal/ws"
+	"github.com/benbeisheim/minechess-backend/pkg/utils/chess"
+)
+
+const defaultDisconnectGrace = 60 * time.Second
+
+// PresenceEvent is the payload of opponentDisconnected and opponentReconnected
+// messages
+type PresenceEvent struct {
+	Color       string     `json:"color"`
+	ReconnectBy *time.Time `json:"reconnectBy,omitempty"` // when the opponent may claim the game
+}
+
+// DisconnectClaim is what a player asks for when their opponent has been gone
+// for longer than the grace period
+type DisconnectClaim string
+
+const (
+	DisconnectClaimWin  DisconnectClaim = "win"
+	DisconnectClaimDraw DisconnectClaim = "draw"
+)
+
+func (g *Game) seat(color string) *ClientPlayer {
+	if color == "white" {
+		return &g.state.Players.White
+	}
+	return &g.state.Players.Black
+}
+
+// setConnected records a seated player's socket connecting or dropping. It
+// returns the event to tell everyone else about, if there is one.
+func (g *Game) setConnected(playerID string, connected bool) (ws.MessageType, *PresenceEvent) {
+	color, ok := g.colorOf(playerID)
+	if !ok {
+		return "", nil
+	}
+	player := g.seat(color)
+	wasGone := player.ReconnectBy != nil
+	player.Connected = connected
+	player.ReconnectBy = nil
+
+	switch {
+	case connected && wasGone:
+		return ws.MessageTypeOpponentReconnected, &PresenceEvent{Color: color}
+	case !connected && !g.state.Status.IsOver():
+		reconnectBy := time.Now().Add(g.state.Rules.disconnectGrace())
+		player.ReconnectBy = &reconnectBy
+		return ws.MessageTypeOpponentDisconnected, &PresenceEvent{Color: color, ReconnectBy: &reconnectBy}
+	}
+	return "", nil
+}
+
+// markAllDisconnected is used after a restart, when nobody is connected yet.
+// Everyone gets the full grace period to come back.
+func (g *Game) markAllDisconnected() {
+	for _, color := range []string{"white", "black"} {
+		if g.seat(color).ID != "" {
+			g.seat(color).Connected = false
+			if g.state.Status == GameStatusInProgress {
+				reconnectBy := time.Now().Add(g.state.Rules.disconnectGrace())
+				g.seat(color).ReconnectBy = &reconnectBy
+			}
+		}
+	}
+}
+
+// ClaimDisconnect ends the game in the claimant's favour once the opponent has
+// stayed away past the grace period. A player without mating material can
+// only claim a draw, and a game where not both sides have moved is aborted.
+func (g *Game) ClaimDisconnect(playerID string, claim DisconnectClaim) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	color, err := g.seatedInLiveGame(playerID)
+	if err != nil {
+		return err
+	}
+	if claim != DisconnectClaimWin && claim != DisconnectClaimDraw {
+		return newGameError(ErrorCodeBadRequest, "unknown claim: %s", claim)
+	}
+	opponent := g.seat(getOtherColor(color))
+	switch {
+	case opponent.Connected || opponent.ReconnectBy == nil:
+		return newGameError(ErrorCodeCannotClaim, "opponent is connected")
+	case time.Now().Before(*opponent.ReconnectBy):
+		return newGameError(ErrorCodeCannotClaim, "opponent has until %s to reconnect", opponent.ReconnectBy.Format(time.RFC3339))
+	}
+
+	switch {
+	case !g.hasBothMoved():
+		g.abort("aborted, " + opponent.Color + " left")
+	case claim == DisconnectClaimDraw:
+		g.finish("draw by Abandonment")
+	case chess.InsufficientMaterial(g.position(), chess.Color(color)):
+		return newGameError(ErrorCodeCannotClaim, "not enough material to win, claim a draw instead")
+	default:
+		g.finish(color + " wins by Abandonment")
+	}
+	g.commit()
+	return nil
+}
+
+// broadcastEvent queues a message for every connection, spectators included
+func (g *Game) broadcastEvent(msgType ws.MessageType, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		fmt.Println("Failed to marshal event", msgType, err)
+		return
+	}
+	msg := ws.Message{Type: msgType, Payload: data}
+
+	g.connections.mu.RLock()
+	defer g.connections.mu.RUnlock()
+	for _, conn := range g.connections.connections {
+		conn.Send(msg)
+	}
+	for conn := range g.connections.spectators {
+		conn.Send(msg)
+	}
+}
@@ -0,0 +1,131 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/benbeisheim/minechess-backend/internal/ws"
+)
+
+// connect registers a new connection for playerID and returns its socket
+func connect(t *testing.T, g *Game, playerID string) (*ws.Connection, *fakeSocket) {
+	t.Helper()
+	conn, socket := newConnection(t)
+	if err := g.RegisterConnection(playerID, conn); err != nil {
+		t.Fatal(err)
+	}
+	return conn, socket
+}
+
+// waitForEvent waits for a message of msgType on socket and returns its payload
+func waitForEvent(t *testing.T, socket *fakeSocket, msgType ws.MessageType) PresenceEvent {
+	t.Helper()
+	var event PresenceEvent
+	socket.waitFor(t, string(msgType), func(written []ws.Message) bool {
+		for _, msg := range written {
+			if msg.Type == msgType {
+				if err := json.Unmarshal(msg.Payload, &event); err != nil {
+					t.Fatal(err)
+				}
+				return true
+			}
+		}
+		return false
+	})
+	return event
+}
+
+// expireGrace makes color's grace period to reconnect run out
+func expireGrace(g *Game, color string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	past := time.Now().Add(-time.Second)
+	g.seat(color).ReconnectBy = &past
+}
+
+func TestOpponentIsToldAboutDisconnects(t *testing.T) {
+	g := newStartedGame(t, Rules{DisconnectGrace: 30})
+	_, whiteSocket := connect(t, g, "white")
+	blackConn, _ := connect(t, g, "black")
+
+	g.UnregisterConnection("black", blackConn)
+	event := waitForEvent(t, whiteSocket, ws.MessageTypeOpponentDisconnected)
+	if event.Color != "black" || event.ReconnectBy == nil {
+		t.Fatalf("got %+v, want black with a deadline to reconnect", event)
+	}
+	if wait := time.Until(*event.ReconnectBy); wait < 29*time.Second || wait > 30*time.Second {
+		t.Errorf("black has %v to reconnect, want the 30s grace period", wait)
+	}
+	if state, _ := g.GetState("white"); state.Players.Black.Connected {
+		t.Error("black is still shown as connected")
+	}
+
+	connect(t, g, "black")
+	if event := waitForEvent(t, whiteSocket, ws.MessageTypeOpponentReconnected); event.Color != "black" {
+		t.Errorf("got %+v, want black", event)
+	}
+	if state, _ := g.GetState("white"); !state.Players.Black.Connected || state.Players.Black.ReconnectBy != nil {
+		t.Error("black isn't shown as back")
+	}
+}
+
+func TestClaimDisconnect(t *testing.T) {
+	tests := []struct {
+		name     string
+		moved    bool
+		claim    DisconnectClaim
+		expired  bool
+		status   GameStatus
+		result   string
+		rejected bool
+	}{
+		{name: "within the grace period", moved: true, claim: DisconnectClaimWin, rejected: true},
+		{name: "win", moved: true, claim: DisconnectClaimWin, expired: true, status: GameStatusFinished, result: "white wins by Abandonment"},
+		{name: "draw", moved: true, claim: DisconnectClaimDraw, expired: true, status: GameStatusFinished, result: "draw by Abandonment"},
+		{name: "before both moved", claim: DisconnectClaimWin, expired: true, status: GameStatusAborted, result: "aborted, black left"},
+		{name: "unknown claim", moved: true, claim: "resign", expired: true, rejected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newStartedGame(t, Rules{})
+			connect(t, g, "white")
+			blackConn, _ := connect(t, g, "black")
+			if tt.moved {
+				mustMove(t, g, "white", move(4, 6, 4, 4, a6))                   // e4
+				mustMove(t, g, "black", move(4, 1, 4, 3, Position{X: 0, Y: 5})) // e5
+			}
+			g.UnregisterConnection("black", blackConn)
+			if tt.expired {
+				expireGrace(g, "black")
+			}
+
+			err := g.ClaimDisconnect("white", tt.claim)
+			state, _ := g.GetState("white")
+			if tt.rejected {
+				if err == nil || state.Status != GameStatusInProgress {
+					t.Fatalf("got %v with status %s, want the claim rejected", err, state.Status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state.Status != tt.status || state.Resolve == nil || *state.Resolve != tt.result {
+				t.Errorf("status %s, result %v, want %s with %q", state.Status, state.Resolve, tt.status, tt.result)
+			}
+		})
+	}
+}
+
+// Nothing can be claimed against a player who is connected
+func TestClaimDisconnectAgainstConnectedOpponent(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	connect(t, g, "white")
+	connect(t, g, "black")
+
+	if err := g.ClaimDisconnect("white", DisconnectClaimWin); err == nil || AsGameError(err).Code != ErrorCodeCannotClaim {
+		t.Fatalf("got %v, want %s", err, ErrorCodeCannotClaim)
+	}
+}
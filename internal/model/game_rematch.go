@@ -0,0 +1,195 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/benbeisheim/minechess-backend/internal/ws"
+	"github.com/benbeisheim/minechess-backend/pkg/utils/chess"
+)
+
+// Once a game is over either player can offer a rematch. When the other agrees,
+// a new game is set up with the same time control and rules and the colours
+// swapped. Games linked this way form a series, which keeps a running score.
+//
+// The game can't create the new game itself, since games are owned by the game
+// manager. So agreeing reserves the new game's ID and returns what to create,
+// and the manager reports back with StartRematch or CancelRematch.
+
+// Series links consecutive rematches between the same two players
+type Series struct {
+	ID    string             `json:"id"`    // the ID of the first game
+	Games []string           `json:"games"` // every game so far, in order
+	Score map[string]float64 `json:"score"` // playerID -> points from the series' finished games
+}
+
+// withResult returns the series with the result of a finished game counted.
+// A Series is shared between copies of the state, so it's never changed in
+// place.
+func (s *Series) withResult(white, black, result string) *Series {
+	score := make(map[string]float64, len(s.Score))
+	for playerID, points := range s.Score {
+		score[playerID] = points
+	}
+	switch winnerOf(result) {
+	case "white":
+		score[white]++
+	case "black":
+		score[black]++
+	default:
+		if strings.HasPrefix(result, "draw") {
+			score[white] += 0.5
+			score[black] += 0.5
+		}
+	}
+	return &Series{ID: s.ID, Games: append([]string{}, s.Games...), Score: score}
+}
+
+// Rematch is the game to create when a rematch has been agreed
+type Rematch struct {
+	ID          string
+	White       string
+	Black       string
+	TimeControl TimeControl
+	Rules       Rules
+	StartFEN    string
+	Series      *Series
+}
+
+// RematchEvent is the payload of a rematch message
+type RematchEvent struct {
+	GameID string  `json:"gameId"`
+	Series *Series `json:"series"`
+}
+
+// OfferRematch offers the opponent a rematch. If they have already offered
+// one, it's agreed to, and the returned Rematch is to be created under
+// rematchID.
+func (g *Game) OfferRematch(playerID string, rematchID string) (*Rematch, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	color, err := g.seatedInFinishedGame(playerID)
+	if err != nil {
+		return nil, err
+	}
+	if offer := g.state.RematchOffer; offer != nil {
+		if *offer == color {
+			return nil, newGameError(ErrorCodeBadRequest, "rematch already offered")
+		}
+		// offering a rematch while the opponent's offer stands agrees to it
+		return g.agreeRematch(rematchID), nil
+	}
+
+	g.state.RematchOffer = &color
+	g.commit()
+	return nil, nil
+}
+
+// AcceptRematch agrees to the opponent's rematch offer. The returned Rematch is
+// to be created under rematchID.
+func (g *Game) AcceptRematch(playerID string, rematchID string) (*Rematch, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	color, err := g.seatedInFinishedGame(playerID)
+	if err != nil {
+		return nil, err
+	}
+	if g.state.RematchOffer == nil || *g.state.RematchOffer == color {
+		return nil, ErrNoRematchOffer
+	}
+	return g.agreeRematch(rematchID), nil
+}
+
+// seatedInFinishedGame returns the colour of playerID, failing unless the game
+// is over and has no rematch yet
+func (g *Game) seatedInFinishedGame(playerID string) (string, error) {
+	color, ok := g.colorOf(playerID)
+	if !ok {
+		return "", ErrNotInGame
+	}
+	if !g.state.Status.IsOver() {
+		return "", newGameError(ErrorCodeNoRematch, "the game is not over yet")
+	}
+	if g.rematchID != "" || g.state.Rematch != nil {
+		return "", newGameError(ErrorCodeNoRematch, "a rematch has already been agreed")
+	}
+	return color, nil
+}
+
+// agreeRematch reserves rematchID and returns the rematch to create
+func (g *Game) agreeRematch(rematchID string) *Rematch {
+	g.rematchID = rematchID
+	g.state.RematchOffer = nil
+
+	white, black := g.state.Players.White.ID, g.state.Players.Black.ID
+	series := g.state.Series
+	if series == nil {
+		// the first game of a series is only known to be one now
+		series = &Series{ID: g.ID, Games: []string{g.ID}, Score: map[string]float64{white: 0, black: 0}}
+		if g.state.Status == GameStatusFinished {
+			series = series.withResult(white, black, g.result())
+		}
+		g.state.Series = series
+	}
+	next := &Series{ID: series.ID, Games: append(append([]string{}, series.Games...), rematchID), Score: series.Score}
+	return &Rematch{
+		ID:          rematchID,
+		White:       black,
+		Black:       white,
+		TimeControl: g.timeControl,
+		Rules:       g.state.Rules,
+		StartFEN:    g.startFEN,
+		Series:      next,
+	}
+}
+
+func (g *Game) result() string {
+	if g.state.Resolve == nil {
+		return ""
+	}
+	return *g.state.Resolve
+}
+
+// StartRematch tells both players, and anyone watching, that the agreed
+// rematch has been created
+func (g *Game) StartRematch(rematch *Rematch) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id := rematch.ID
+	g.state.Rematch = &id
+	g.broadcastEvent(ws.MessageTypeRematch, RematchEvent{GameID: id, Series: rematch.Series})
+	g.commit()
+}
+
+// CancelRematch releases the reserved rematch if it couldn't be created, so it
+// can be offered again
+func (g *Game) CancelRematch() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.rematchID = ""
+	g.commit()
+}
+
+// NewRematchGame creates the game for an agreed rematch, with both players
+// already seated
+func NewRematchGame(r *Rematch) (*Game, error) {
+	fen := r.StartFEN
+	if fen == "" {
+		fen = chess.StartingFEN
+	}
+	g, err := NewGameFromFEN(r.ID, fen, r.TimeControl, r.Rules)
+	if err != nil {
+		return nil, err
+	}
+	g.state.Series = r.Series
+	if _, err := g.AddPlayer(r.White); err != nil {
+		return nil, err
+	}
+	if _, err := g.AddPlayer(r.Black); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
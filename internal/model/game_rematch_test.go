@@ -0,0 +1,113 @@
+package model
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// agreeRematch has black offer a rematch of g and white accept it
+func agreeRematch(t *testing.T, g *Game, rematchID string) *Rematch {
+	t.Helper()
+	if rematch, err := g.OfferRematch("black", ""); err != nil || rematch != nil {
+		t.Fatalf("got %v, %v, want the offer to stand", rematch, err)
+	}
+	rematch, err := g.AcceptRematch("white", rematchID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rematch
+}
+
+func TestRematchSwapsColours(t *testing.T) {
+	tc := TimeControl{Base: 180, Increment: 2}
+	rules := Rules{Takebacks: true}
+	g := NewGame("first", tc, rules)
+	for _, playerID := range []string{"white", "black"} {
+		if _, err := g.AddPlayer(playerID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.Resign("black"); err != nil {
+		t.Fatal(err)
+	}
+
+	rematch := agreeRematch(t, g, "second")
+	if rematch.ID != "second" || rematch.White != "black" || rematch.Black != "white" {
+		t.Fatalf("got %+v, want second with the colours swapped", rematch)
+	}
+	if rematch.TimeControl != tc || rematch.Rules != rules {
+		t.Errorf("rematch plays %+v under %+v, want %+v under %+v", rematch.TimeControl, rematch.Rules, tc, rules)
+	}
+
+	next, err := NewRematchGame(rematch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, _ := next.GetState("white")
+	if state.Status != GameStatusInProgress || state.Players.White.ID != "black" || state.Players.Black.ID != "white" {
+		t.Fatalf("rematch is %s with %s as white, want it under way with black as white", state.Status, state.Players.White.ID)
+	}
+	if want := []string{"first", "second"}; state.Series == nil || !reflect.DeepEqual(state.Series.Games, want) {
+		t.Errorf("series %+v, want games %v", state.Series, want)
+	}
+}
+
+func TestSeriesKeepsScore(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	if err := g.Resign("black"); err != nil {
+		t.Fatal(err)
+	}
+	next, err := NewRematchGame(agreeRematch(t, g, "second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := next.OfferDraw("white"); err != nil {
+		t.Fatal(err)
+	}
+	if err := next.AcceptDraw("black"); err != nil {
+		t.Fatal(err)
+	}
+
+	state, _ := next.GetState("white")
+	if want := map[string]float64{"white": 1.5, "black": 0.5}; !reflect.DeepEqual(state.Series.Score, want) {
+		t.Errorf("score %v, want %v", state.Series.Score, want)
+	}
+}
+
+func TestRematchOffers(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	if _, err := g.OfferRematch("white", "second"); err == nil || AsGameError(err).Code != ErrorCodeNoRematch {
+		t.Fatalf("offering during the game: got %v, want %s", err, ErrorCodeNoRematch)
+	}
+	if err := g.Resign("black"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.AcceptRematch("black", "second"); !errors.Is(err, ErrNoRematchOffer) {
+		t.Errorf("accepting without an offer: got %v, want %v", err, ErrNoRematchOffer)
+	}
+	if _, err := g.OfferRematch("spectator", "second"); !errors.Is(err, ErrNotInGame) {
+		t.Errorf("spectator offering: got %v, want %v", err, ErrNotInGame)
+	}
+	if _, err := g.OfferRematch("white", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.AcceptRematch("white", "second"); !errors.Is(err, ErrNoRematchOffer) {
+		t.Errorf("accepting own offer: got %v, want %v", err, ErrNoRematchOffer)
+	}
+
+	// offering back agrees to the standing offer, after which there's nothing left to agree
+	rematch, err := g.OfferRematch("black", "second")
+	if err != nil || rematch == nil || rematch.ID != "second" {
+		t.Fatalf("got %+v, %v, want the rematch agreed", rematch, err)
+	}
+	if _, err := g.OfferRematch("white", "third"); err == nil {
+		t.Error("a second rematch was offered")
+	}
+
+	// a rematch that couldn't be created can be offered again
+	g.CancelRematch()
+	if _, err := g.OfferRematch("white", ""); err != nil {
+		t.Errorf("offering after a cancelled rematch: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package model
+
+// A client may tag a move with a request ID of its choosing, and is told
+// whether that move was accepted or rejected. After a reconnect it can't know
+// whether a move it sent made it, so it sends it again with the same ID; the
+// game answers from what it remembers instead of making the move twice.
+
+// maxRequests is how many recent request IDs are remembered for each player
+const maxRequests = 32
+
+// moveRequest is the outcome of a move sent with a request ID
+type moveRequest struct {
+	ID  string     `json:"id"`
+	Err *GameError `json:"error,omitempty"` // nil if the move was made
+}
+
+// MakeMoveRequest makes a move tagged with requestID, unless a move with that
+// ID has already been made or rejected, in which case it returns the same
+// result as the first time. An empty requestID is never deduplicated.
+func (g *Game) MakeMoveRequest(playerID string, requestID string, move WSMove) error {
+	if requestID == "" {
+		return g.MakeMove(playerID, move)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if request, seen := g.findRequest(playerID, requestID); seen {
+		if request.Err != nil {
+			return request.Err
+		}
+		return nil
+	}
+
+	request := moveRequest{ID: requestID}
+	if err := g.makeMove(playerID, move); err != nil {
+		request.Err = AsGameError(err)
+	}
+	g.rememberRequest(playerID, request)
+	if request.Err != nil {
+		return request.Err
+	}
+	return nil
+}
+
+func (g *Game) findRequest(playerID string, requestID string) (moveRequest, bool) {
+	for _, request := range g.requests[playerID] {
+		if request.ID == requestID {
+			return request, true
+		}
+	}
+	return moveRequest{}, false
+}
+
+func (g *Game) rememberRequest(playerID string, request moveRequest) {
+	if g.requests == nil {
+		g.requests = make(map[string][]moveRequest)
+	}
+	requests := append(g.requests[playerID], request)
+	if len(requests) > maxRequests {
+		requests = requests[len(requests)-maxRequests:]
+	}
+	g.requests[playerID] = requests
+}
+
+// copyRequests copies the remembered requests for a snapshot
+func copyRequests(requests map[string][]moveRequest) map[string][]moveRequest {
+	if len(requests) == 0 {
+		return nil
+	}
+	copied := make(map[string][]moveRequest, len(requests))
+	for playerID, list := range requests {
+		copied[playerID] = append([]moveRequest{}, list...)
+	}
+	return copied
+}
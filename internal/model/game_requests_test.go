@@ -0,0 +1,59 @@
+package model
+
+import "testing"
+
+func TestRepeatedRequestIsMadeOnce(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	e4 := move(4, 6, 4, 4, a6)
+	for i := 0; i < 2; i++ {
+		if err := g.MakeMoveRequest("white", "r1", e4); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+	}
+	state, _ := g.GetState("white")
+	if plyCount(state.MoveHistory) != 1 || state.ToMove != "black" {
+		t.Fatalf("%d plies with %s to move, want e4 made once", plyCount(state.MoveHistory), state.ToMove)
+	}
+
+	// request IDs are per player
+	if err := g.MakeMoveRequest("black", "r1", move(4, 1, 4, 3, Position{X: 0, Y: 5})); err != nil { // e5
+		t.Fatal(err)
+	}
+}
+
+// A rejected request stays rejected, even once the same move would be allowed
+func TestRepeatedRequestGetsSameRejection(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	e5 := move(4, 1, 4, 3, Position{X: 0, Y: 5})
+	err := g.MakeMoveRequest("black", "r1", e5)
+	if err == nil || AsGameError(err).Code != ErrorCodeNotYourTurn {
+		t.Fatalf("got %v, want %s", err, ErrorCodeNotYourTurn)
+	}
+	mustMove(t, g, "white", move(4, 6, 4, 4, a6)) // e4
+
+	again := g.MakeMoveRequest("black", "r1", e5)
+	if again == nil || *AsGameError(again) != *AsGameError(err) {
+		t.Fatalf("got %v, want %v again", again, err)
+	}
+	if state, _ := g.GetState("black"); state.ToMove != "black" {
+		t.Error("the repeated request was made")
+	}
+}
+
+// Requests are remembered across a restart, when clients are most likely to
+// send them again
+func TestRequestsSurviveRestore(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	e4 := move(4, 6, 4, 4, a6)
+	if err := g.MakeMoveRequest("white", "r1", e4); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := RestoreGame(g.Snapshot())
+	if err := restored.MakeMoveRequest("white", "r1", e4); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := restored.GetState("white"); plyCount(state.MoveHistory) != 1 {
+		t.Errorf("%d plies, want e4 made once", plyCount(state.MoveHistory))
+	}
+}
@@ -0,0 +1,67 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/benbeisheim/minechess-backend/internal/ws"
+)
+
+// RegisterSpectator adds a read-only connection. Any number of people can
+// watch any game, including its players from another tab.
+func (g *Game) RegisterSpectator(playerID string, conn *ws.Connection) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.connections.mu.Lock()
+	g.connections.spectators[conn] = playerID
+	count := len(g.connections.spectators)
+	g.connections.mu.Unlock()
+	fmt.Printf("Registered spectator %p (%s), %d watching\n", conn, playerID, count)
+
+	g.state.SpectatorCount = count
+	g.commit() // also sends the initial state
+}
+
+func (g *Game) UnregisterSpectator(conn *ws.Connection) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.connections.mu.Lock()
+	delete(g.connections.spectators, conn)
+	delete(g.connections.sent, conn)
+	count := len(g.connections.spectators)
+	g.connections.mu.Unlock()
+
+	g.state.SpectatorCount = count
+	g.commit()
+}
+
+// broadcastToSpectators queues the spectators' view of the state, held back by
+// the game's spectator delay if it has one so it's no use for live assistance.
+// view must not share anything with g.state, which the delayed send can't
+// lock. The caller must hold g.connections.mu for writing.
+func (g *Game) broadcastToSpectators(view GameState, delay time.Duration) {
+	seq := g.seq
+	if delay == 0 {
+		g.connections.sendToSpectators(view, seq)
+		return
+	}
+	time.AfterFunc(delay, func() {
+		g.connections.mu.Lock()
+		defer g.connections.mu.Unlock()
+		g.connections.sendToSpectators(view, seq)
+	})
+}
+
+func (gc *GameConnections) sendToSpectators(view GameState, seq uint64) {
+	// spectators all get the same view, so those sent the same state before
+	// get the same message
+	built := make(map[uint64]ws.Message)
+	for conn := range gc.spectators {
+		if err := gc.sendState(conn, view, seq, built); err != nil {
+			fmt.Println("Failed to marshal spectator state to JSON", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package model
+
+// Takebacks undo moves by going back to a copy of the game taken before them,
+// rather than by reversing each ply: a ply can capture, castle, promote,
+// explode a mine and arm a new one, and undoing all of that by hand is easy to
+// get subtly wrong. A player takes back at most their own last move, and the
+// opponent's reply to it if there was one, so only the last two copies are kept.
+
+const maxUndoPlies = 2
+
+// undoPoint is the game as it was before a ply
+type undoPoint struct {
+	State      GameState     `json:"state"`
+	Mine       *Position     `json:"mine"`
+	WhiteClock ClockSnapshot `json:"whiteClock"`
+	BlackClock ClockSnapshot `json:"blackClock"`
+}
+
+// undoPoint copies what a takeback of the next ply needs to restore
+func (g *Game) undoPoint() undoPoint {
+	state := g.state.clone()
+	var mine *Position
+	if g.mine != nil {
+		mineCopy := *g.mine
+		mine = &mineCopy
+	}
+	return undoPoint{
+		State:      state,
+		Mine:       mine,
+		WhiteClock: g.whiteClock.snapshot(),
+		BlackClock: g.blackClock.snapshot(),
+	}
+}
+
+func (g *Game) pushUndo(point undoPoint) {
+	g.undo = append(g.undo, point)
+	if len(g.undo) > maxUndoPlies {
+		g.undo = g.undo[len(g.undo)-maxUndoPlies:]
+	}
+}
+
+// takebackPlies is how many plies color's takeback undoes: their own last move,
+// and the opponent's reply if it's their turn again
+func (g *Game) takebackPlies(color string) int {
+	if color == g.state.ToMove {
+		return 2
+	}
+	return 1
+}
+
+func (g *Game) setTakebackRequest(color string) {
+	g.state.TakebackRequest = nil
+	if color != "" {
+		g.state.TakebackRequest = &color
+	}
+}
+
+// RequestTakeback asks the opponent to agree to undo the player's last move
+func (g *Game) RequestTakeback(playerID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	color, err := g.seatedInLiveGame(playerID)
+	if err != nil {
+		return err
+	}
+	if !g.state.Rules.Takebacks {
+		return ErrTakebacksOff
+	}
+	if g.state.TakebackRequest != nil {
+		return newGameError(ErrorCodeBadRequest, "a takeback has already been requested")
+	}
+	if len(g.undo) < g.takebackPlies(color) {
+		return newGameError(ErrorCodeNoTakeback, "no move of yours to take back")
+	}
+
+	g.setTakebackRequest(color)
+	g.commit()
+	return nil
+}
+
+// AcceptTakeback agrees to the opponent's takeback request, taking the game
+// back to before their last move
+func (g *Game) AcceptTakeback(playerID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	color, err := g.seatedInLiveGame(playerID)
+	if err != nil {
+		return err
+	}
+	if g.state.TakebackRequest == nil || *g.state.TakebackRequest == color {
+		return ErrNoTakeback
+	}
+
+	g.takeBack(g.takebackPlies(*g.state.TakebackRequest))
+	g.commit()
+	return nil
+}
+
+func (g *Game) DeclineTakeback(playerID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	color, err := g.seatedInLiveGame(playerID)
+	if err != nil {
+		return err
+	}
+	if g.state.TakebackRequest == nil || *g.state.TakebackRequest == color {
+		return ErrNoTakeback
+	}
+
+	g.setTakebackRequest("")
+	g.commit()
+	return nil
+}
+
+// takeBack restores the game to before its last plies, clocks included. Who is
+// playing, and who is connected or watching, isn't part of that.
+func (g *Game) takeBack(plies int) {
+	point := g.undo[len(g.undo)-plies]
+	g.undo = g.undo[:len(g.undo)-plies]
+
+	players, spectators := g.state.Players, g.state.SpectatorCount
+	g.state = point.State
+	g.state.Players = players
+	g.state.SpectatorCount = spectators
+	g.state.Sound = ""
+	g.mine = point.Mine
+	g.mines = g.mines[:len(g.mines)-plies]
+	g.positions = g.positions[:len(g.positions)-plies]
+	g.setTakebackRequest("")
+	g.setDrawOffer(nil)
+	g.clearPremoves()
+
+	g.stopFlagTimer()
+	g.whiteClock = restoreClock(g.timeControl, ClockSnapshot{TimeLeft: point.WhiteClock.TimeLeft})
+	g.blackClock = restoreClock(g.timeControl, ClockSnapshot{TimeLeft: point.BlackClock.TimeLeft})
+	// like after a move, the clocks only run once the first move has been made
+	if len(g.mines) > 0 {
+		g.clockFor(g.state.ToMove).Start()
+		g.armFlagTimer()
+	}
+	g.armFirstMoveTimer()
+	g.updateClientClocks()
+}
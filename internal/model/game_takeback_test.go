@@ -0,0 +1,105 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+// newTakebackGame returns a game with takebacks on after e4 and e5
+func newTakebackGame(t *testing.T) *Game {
+	t.Helper()
+	g := newStartedGame(t, Rules{Takebacks: true})
+	mustMove(t, g, "white", move(4, 6, 4, 4, a6))                   // e4, arming a6
+	mustMove(t, g, "black", move(4, 1, 4, 3, Position{X: 0, Y: 5})) // e5, arming a3
+	return g
+}
+
+// Black takes back e5 while it's white's turn, which undoes only black's move
+func TestTakebackRestoresGame(t *testing.T) {
+	g := newStartedGame(t, Rules{Takebacks: true})
+	mustMove(t, g, "white", move(4, 6, 4, 4, a6)) // e4, arming a6
+	afterE4 := g.Snapshot()
+	mustMove(t, g, "black", move(4, 1, 4, 3, Position{X: 0, Y: 5})) // e5, arming a3
+	if err := g.RequestTakeback("black"); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := g.GetState("white"); state.TakebackRequest == nil || *state.TakebackRequest != "black" {
+		t.Fatalf("takeback request %v, want black's", state.TakebackRequest)
+	}
+	if err := g.AcceptTakeback("white"); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := g.Snapshot()
+	state := snapshot.State
+	if state.ToMove != "black" || plyCount(state.MoveHistory) != 1 || state.TakebackRequest != nil {
+		t.Fatalf("%s to move after %d plies, want black to move after e4", state.ToMove, plyCount(state.MoveHistory))
+	}
+	if state.Board.Board[1][4] == nil || state.Board.Board[3][4] != nil {
+		t.Error("black's pawn isn't back on e7")
+	}
+	if snapshot.Mine == nil || *snapshot.Mine != a6 {
+		t.Errorf("armed mine %v, want white's on a6 again", snapshot.Mine)
+	}
+	if !snapshot.BlackClock.IsRunning || snapshot.WhiteClock.IsRunning {
+		t.Error("black's clock isn't the one running")
+	}
+	if snapshot.WhiteClock.TimeLeft != afterE4.WhiteClock.TimeLeft {
+		t.Errorf("white has %v, want the %v they had after e4", snapshot.WhiteClock.TimeLeft, afterE4.WhiteClock.TimeLeft)
+	}
+}
+
+// White takes back e4 on their own turn, which also undoes black's reply
+func TestTakebackOnOwnTurnUndoesReply(t *testing.T) {
+	g := newTakebackGame(t)
+	if err := g.RequestTakeback("white"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.AcceptTakeback("black"); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := g.Snapshot()
+	if snapshot.State.ToMove != "white" || plyCount(snapshot.State.MoveHistory) != 0 || snapshot.Mine != nil {
+		t.Fatalf("%s to move after %d plies with mine %v, want the starting position", snapshot.State.ToMove, plyCount(snapshot.State.MoveHistory), snapshot.Mine)
+	}
+	mustMove(t, g, "white", move(3, 6, 3, 4, a6)) // d4
+}
+
+func TestTakebackRequests(t *testing.T) {
+	g := newTakebackGame(t)
+	if err := g.AcceptTakeback("white"); !errors.Is(err, ErrNoTakeback) {
+		t.Errorf("accepting without a request: got %v, want %v", err, ErrNoTakeback)
+	}
+	if err := g.RequestTakeback("black"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.RequestTakeback("white"); err == nil {
+		t.Error("a second request was made while one is pending")
+	}
+	if err := g.AcceptTakeback("black"); !errors.Is(err, ErrNoTakeback) {
+		t.Errorf("accepting own request: got %v, want %v", err, ErrNoTakeback)
+	}
+	if err := g.DeclineTakeback("white"); err != nil {
+		t.Fatal(err)
+	}
+	state, _ := g.GetState("white")
+	if state.TakebackRequest != nil || plyCount(state.MoveHistory) != 2 {
+		t.Error("the declined takeback changed the game")
+	}
+}
+
+func TestTakebackNeedsMoveToTakeBack(t *testing.T) {
+	g := newStartedGame(t, Rules{Takebacks: true})
+	if err := g.RequestTakeback("white"); err == nil || AsGameError(err).Code != ErrorCodeNoTakeback {
+		t.Errorf("got %v, want %s", err, ErrorCodeNoTakeback)
+	}
+}
+
+func TestTakebacksOff(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	mustMove(t, g, "white", move(4, 6, 4, 4, a6)) // e4
+	if err := g.RequestTakeback("white"); !errors.Is(err, ErrTakebacksOff) {
+		t.Errorf("got %v, want %v", err, ErrTakebacksOff)
+	}
+}
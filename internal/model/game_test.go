@@ -0,0 +1,206 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/benbeisheim/minechess-backend/internal/ws"
+)
+
+// A mine in a custom start position has to be one its placer, the side that
+// just moved, could have placed under the game's rules
+func TestNewGameFromFENValidatesMine(t *testing.T) {
+	const afterE4 = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1 "
+	tests := []struct {
+		name  string
+		mine  string
+		rules Rules
+		ok    bool
+	}{
+		{"empty square", "a6", Rules{}, true},
+		{"no mine", "-", Rules{}, true},
+		{"under the placer's own pawn", "e4", Rules{}, false},
+		{"under the king", "e8", Rules{MinesUnderPieces: true}, false},
+		{"under an enemy pawn", "d7", Rules{}, false},
+		{"under an enemy pawn when allowed", "d7", Rules{MinesUnderPieces: true}, true},
+		{"next to the king", "f7", Rules{MinesUnderPieces: true}, true},
+		{"next to the king when not allowed", "f7", Rules{MinesUnderPieces: true, NoMinesNearKing: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewGameFromFEN("test", afterE4+tt.mine, TimeControl{Base: 300}, tt.rules)
+			if tt.ok && err != nil {
+				t.Errorf("got %v, want the game", err)
+			}
+			var gameErr *GameError
+			if !tt.ok && (!errors.As(err, &gameErr) || gameErr.Code != ErrorCodeInvalidMine) {
+				t.Errorf("got %v, want an invalid mine", err)
+			}
+		})
+	}
+}
+
+// Only the player seated as the side to move may move, and only its pieces
+func TestMakeMoveChecksOwnership(t *testing.T) {
+	tests := []struct {
+		name     string
+		playerID string
+		move     WSMove
+		code     ErrorCode
+	}{
+		{"spectator", "spectator", move(4, 6, 4, 4, a6), ErrorCodeNotInGame},
+		{"no player", "", move(4, 6, 4, 4, a6), ErrorCodeNotInGame},
+		{"opponent", "black", move(4, 6, 4, 4, a6), ErrorCodeNotYourTurn},
+		{"opponent's piece", "black", move(4, 1, 4, 3, a6), ErrorCodeNotYourTurn},
+		{"owner with the opponent's piece", "white", move(4, 1, 4, 3, a6), ErrorCodeNotYourTurn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newStartedGame(t, Rules{})
+			err := g.MakeMove(tt.playerID, tt.move)
+			var gameErr *GameError
+			if !errors.As(err, &gameErr) || gameErr.Code != tt.code {
+				t.Fatalf("got %v, want %s", err, tt.code)
+			}
+			if state, _ := g.GetState("white"); state.ToMove != "white" || plyCount(state.MoveHistory) != 0 {
+				t.Error("the rejected move was played")
+			}
+		})
+	}
+}
+
+// Commands on behalf of a side are rejected for anyone not seated in the game
+func TestCommandsRejectNonPlayers(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	commands := map[string]func(string) error{
+		"resign":     g.Resign,
+		"offer draw": g.OfferDraw,
+		"abort":      g.Abort,
+		"premove":    func(playerID string) error { return g.Premove(playerID, move(4, 1, 4, 3, a6)) },
+	}
+	for name, command := range commands {
+		if err := command("spectator"); !errors.Is(err, ErrNotInGame) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrNotInGame)
+		}
+	}
+}
+
+func TestPromotion(t *testing.T) {
+	const fen = "4k3/P7/8/8/8/8/8/4K3 w - - 0 1 -"
+	tests := []struct {
+		name      string
+		promotion PieceType
+		ok        bool
+	}{
+		{"queen", Queen, true},
+		{"knight", Knight, true},
+		{"missing", "", false},
+		{"king", King, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := NewGameFromFEN("test", fen, TimeControl{Base: 300}, Rules{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, playerID := range []string{"white", "black"} {
+				if _, err := g.AddPlayer(playerID); err != nil {
+					t.Fatal(err)
+				}
+			}
+			m := move(0, 1, 0, 0, Position{X: 7, Y: 2}) // a8
+			m.Promotion = tt.promotion
+			err = g.MakeMove("white", m)
+
+			state, _ := g.GetState("white")
+			if !tt.ok {
+				var gameErr *GameError
+				if !errors.As(err, &gameErr) || gameErr.Code != ErrorCodeIllegalMove {
+					t.Fatalf("got %v, want an illegal move", err)
+				}
+				if state.Board.Board[1][0] == nil || state.Board.Board[1][0].Type != Pawn {
+					t.Error("the pawn moved")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if piece := state.Board.Board[0][0]; piece == nil || piece.Type != tt.promotion {
+				t.Errorf("a8 = %+v, want a %s", piece, tt.promotion)
+			}
+			if state.PromotionSquare == nil || *state.PromotionSquare != (Position{X: 0, Y: 0}) {
+				t.Errorf("promotion square %v, want a8", state.PromotionSquare)
+			}
+			if state.PromotionPiece == nil || *state.PromotionPiece != tt.promotion {
+				t.Errorf("promotion piece %v, want %s", state.PromotionPiece, tt.promotion)
+			}
+		})
+	}
+}
+
+// A player connecting again takes over from their old connection, which is
+// closed, and is sent the full state
+func TestNewConnectionSupersedesOld(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	connect(t, g, "white")
+	mustMove(t, g, "white", move(4, 6, 4, 4, a6)) // e4
+	old, oldSocket := connect(t, g, "black")
+	_, blackSocket := connect(t, g, "black")
+
+	if reason := oldSocket.waitForClose(t); reason != "superseded" {
+		t.Errorf("old connection closed with %q, want superseded", reason)
+	}
+	written := blackSocket.waitFor(t, "the state", func(written []ws.Message) bool { return len(written) > 0 })
+	if written[0].Type != ws.MessageTypeGameState {
+		t.Fatalf("new connection was first sent %s, want the full state", written[0].Type)
+	}
+	var state GameState
+	if err := json.Unmarshal(written[0].Payload, &state); err != nil {
+		t.Fatal(err)
+	}
+	if plyCount(state.MoveHistory) != 1 {
+		t.Errorf("full state has %d plies, want 1", plyCount(state.MoveHistory))
+	}
+
+	// the old socket closing afterwards must not unregister the new one
+	g.UnregisterConnection("black", old)
+	if state, _ := g.GetState("white"); !state.Players.Black.Connected {
+		t.Error("black is shown as disconnected")
+	}
+	mustMove(t, g, "black", move(4, 1, 4, 3, Position{X: 0, Y: 5})) // e5
+	blackSocket.waitFor(t, "black's move", func(written []ws.Message) bool {
+		return len(written) > 0 && written[len(written)-1].Seq > written[0].Seq
+	})
+}
+
+// States broadcast by commands racing each other still reach a connection in
+// the order they were made
+func TestStatesArriveInOrder(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	_, socket := connect(t, g, "black")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.OfferDraw("white")
+			g.WithdrawDrawOffer("white")
+		}()
+	}
+	wg.Wait()
+	g.Resign("white")
+
+	written := socket.waitFor(t, "the result", func(written []ws.Message) bool {
+		return len(written) > 0 && strings.Contains(string(written[len(written)-1].Payload), "wins by Resignation")
+	})
+	for i := 1; i < len(written); i++ {
+		if written[i].Seq <= written[i-1].Seq {
+			t.Fatalf("seq %d arrived after %d", written[i].Seq, written[i-1].Seq)
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package model
 
+import "github.com/benbeisheim/minechess-backend/pkg/utils/chess"
+
 type WSMove struct {
-	From      Position
-	To        Position
-	Promotion PieceType
-	Mine      Position
+	From      Position  `json:"from"`
+	To        Position  `json:"to"`
+	Promotion PieceType `json:"promotion,omitempty"`
+	Mine      *Position `json:"mine"` // nil places no mine, if the game's rules allow it
 }
 
 type CastleRookMove struct {
@@ -20,6 +22,7 @@ type Ply struct {
 	CastleRookMove *CastleRookMove `json:"castleRookMove"`
 	Promotion      PieceType       `json:"promotion"`
 	Notation       string          `json:"notation"`
+	Mine           *Position       `json:"mine,omitempty"` // only filled in for archived games
 }
 
 type Move struct {
@@ -31,3 +34,7 @@ type SimpleMove struct {
 	From Position `json:"from"`
 	To   Position `json:"to"`
 }
+
+func (m WSMove) toChessMove() chess.Move {
+	return chess.Move{From: m.From.toSquare(), To: m.To.toSquare(), Promotion: chess.PieceType(m.Promotion)}
+}
@@ -0,0 +1,220 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/benbeisheim/minechess-backend/pkg/utils/chess"
+)
+
+// PGN gives the time control in PGN TimeControl tag format
+func (tc TimeControl) PGN() string {
+	if tc.DaysPerMove > 0 {
+		return fmt.Sprintf("1/%d", int(tc.InitialTime().Seconds()))
+	}
+	return fmt.Sprintf("%d+%d", tc.Base, tc.Increment)
+}
+
+func (a ArchivedGame) pgnResult() string {
+	switch {
+	case a.Reason == "":
+		return chess.ResultOngoing
+	case a.Winner == "white":
+		return chess.ResultWhiteWins
+	case a.Winner == "black":
+		return chess.ResultBlackWins
+	}
+	return chess.ResultDraw
+}
+
+func pgnName(playerID string) string {
+	if playerID == "" {
+		return "?"
+	}
+	return playerID
+}
+
+// PGN exports the game, replaying it through the rules engine to produce
+// proper SAN. Each ply's mine is written as a comment.
+func (a ArchivedGame) PGN() (chess.PGNGame, error) {
+	date := "????.??.??"
+	if !a.StartedAt.IsZero() {
+		date = a.StartedAt.Format("2006.01.02")
+	}
+	result := a.pgnResult()
+	game := chess.PGNGame{
+		Tags: []chess.PGNTag{
+			{Name: "Event", Value: "Minechess game"},
+			{Name: "Site", Value: "Minechess"},
+			{Name: "Date", Value: date},
+			{Name: "Round", Value: "-"},
+			{Name: "White", Value: pgnName(a.White)},
+			{Name: "Black", Value: pgnName(a.Black)},
+			{Name: "Result", Value: result},
+			{Name: "TimeControl", Value: a.TimeControl.PGN()},
+			{Name: "Variant", Value: "Minechess"},
+		},
+		Result: result,
+	}
+	if a.Reason != "" {
+		game.Tags = append(game.Tags, chess.PGNTag{Name: "Termination", Value: a.Reason})
+	}
+
+	pos := chess.StartingPosition()
+	var mine *chess.Square
+	if a.StartFEN != "" {
+		start, startMine, err := chess.ParseFEN(a.StartFEN)
+		if err != nil {
+			return game, err
+		}
+		pos, mine = start, startMine
+		game.Tags = append(game.Tags, chess.PGNTag{Name: "SetUp", Value: "1"}, chess.PGNTag{Name: "FEN", Value: a.StartFEN})
+	}
+
+	pos.Options = a.Rules.chessOptions()
+
+	plies := []Ply{}
+	for _, move := range a.MoveHistory {
+		if move.WhitePly.Piece != nil {
+			plies = append(plies, move.WhitePly)
+		}
+		if move.BlackPly.Piece != nil {
+			plies = append(plies, move.BlackPly)
+		}
+	}
+
+	for i, ply := range plies {
+		move := chess.Move{From: ply.From.toSquare(), To: ply.To.toSquare(), Promotion: chess.PieceType(ply.Promotion)}
+		san := pos.SAN(move, mine)
+		next, _, err := pos.Apply(move, mine)
+		if err != nil {
+			return game, fmt.Errorf("ply %d (%s): %w", i+1, ply.Notation, err)
+		}
+		pgnPly := chess.PGNPly{SAN: san}
+		mine = nil
+		if ply.Mine != nil {
+			square := ply.Mine.toSquare()
+			pgnPly.Mine = &square
+			mine = &square
+		}
+		game.Plies = append(game.Plies, pgnPly)
+		pos = next
+	}
+	return game, nil
+}
+
+// PGN exports the game so far for playerID. The mine that is still armed stays
+// hidden until the game is over.
+func (g *Game) PGN(playerID string) (chess.PGNGame, error) {
+	g.mu.Lock()
+	if err := g.requireLiveView(playerID); err != nil {
+		g.mu.Unlock()
+		return chess.PGNGame{}, err
+	}
+	snapshot := g.snapshot()
+	g.mu.Unlock()
+
+	record := NewArchivedGame(snapshot)
+	if record.Reason == "" && len(record.MoveHistory) > 0 {
+		last := &record.MoveHistory[len(record.MoveHistory)-1]
+		if last.BlackPly.Piece != nil {
+			last.BlackPly.Mine = nil
+		} else {
+			last.WhitePly.Mine = nil
+		}
+	}
+	return record.PGN()
+}
+
+// NewGameFromPGN rebuilds a game by replaying a PGN file under rules. The White
+// and Black tags become the player IDs. Illegal moves, mines the rules don't
+// allow, or moves after the game has ended, are rejected. An export of a game
+// in progress leaves out the mine that is still armed, so the last ply of an
+// unfinished game may have none.
+func NewGameFromPGN(id string, pgn chess.PGNGame, timeControl TimeControl, rules Rules) (*Game, error) {
+	if variant, ok := pgn.Tag("Variant"); ok && !strings.EqualFold(variant, "Minechess") {
+		return nil, fmt.Errorf("unsupported variant: %s", variant)
+	}
+	fen, ok := pgn.Tag("FEN")
+	if !ok {
+		fen = chess.StartingFEN
+	}
+	g, err := NewGameFromFEN(id, fen, timeControl, rules)
+	if err != nil {
+		return nil, err
+	}
+	moves, err := pgn.Replay(g.position(), g.mineSquare())
+	if err != nil {
+		return nil, err
+	}
+	result := pgnOutcome(pgn)
+
+	if white, ok := pgn.Tag("White"); ok && white != "?" {
+		g.state.Players.White = ClientPlayer{ID: white, Color: "white", TimeLeft: clientTimeLeft(g.whiteClock.GetTimeLeft())}
+	}
+	if black, ok := pgn.Tag("Black"); ok && black != "?" {
+		g.state.Players.Black = ClientPlayer{ID: black, Color: "black", TimeLeft: clientTimeLeft(g.blackClock.GetTimeLeft())}
+	}
+	if g.state.Players.White.ID == "" || g.state.Players.Black.ID == "" {
+		if len(moves) > 0 || result != "" {
+			return nil, errors.New("a game that has started needs both players")
+		}
+		return g, nil
+	}
+
+	// the moves are replayed as if the game were being played
+	g.startedAt = time.Now()
+	g.transition(GameStatusInProgress)
+	for i, move := range moves {
+		wsMove := WSMove{
+			From:      positionFromSquare(move.From),
+			To:        positionFromSquare(move.To),
+			Promotion: PieceType(move.Promotion),
+		}
+		if mine := pgn.Plies[i].Mine; mine != nil {
+			square := positionFromSquare(*mine)
+			wsMove.Mine = &square
+		}
+		hidden := wsMove.Mine == nil && i == len(moves)-1 && result == ""
+		if err := g.validateMine(wsMove); err != nil && !hidden {
+			return nil, fmt.Errorf("ply %d: %w", i+1, err)
+		}
+		if err := g.executeMove(wsMove); err != nil {
+			return nil, fmt.Errorf("ply %d: %w", i+1, err)
+		}
+	}
+
+	// the result of a game that didn't end on the board, e.g. by resignation
+	if g.state.Resolve == nil && result != "" {
+		if termination, ok := pgn.Tag("Termination"); ok && strings.HasPrefix(termination, result) {
+			result = termination
+		}
+		g.finish(result)
+	}
+	if g.state.Status == GameStatusInProgress {
+		// play goes on from here, so the clocks run as they would after a move
+		if len(moves) > 0 {
+			g.clockFor(g.state.ToMove).Start()
+			g.armFlagTimer()
+		}
+		g.armFirstMoveTimer()
+		g.updateClientClocks()
+	}
+	return g, nil
+}
+
+// pgnOutcome turns the result of a PGN game into a game result, or "" if the
+// game is still going
+func pgnOutcome(pgn chess.PGNGame) string {
+	switch pgn.Result {
+	case chess.ResultWhiteWins:
+		return "white wins"
+	case chess.ResultBlackWins:
+		return "black wins"
+	case chess.ResultDraw:
+		return "draw"
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benbeisheim/minechess-backend/pkg/utils/chess"
+)
+
+func TestPGNRoundTrip(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	mustMove(t, g, "white", move(4, 6, 4, 4, Position{X: 0, Y: 2})) // e4
+	mustMove(t, g, "black", move(3, 1, 3, 3, Position{X: 0, Y: 5})) // d5
+	mustMove(t, g, "white", move(4, 4, 3, 3, Position{X: 7, Y: 2})) // exd5
+	if err := g.Resign("black"); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := g.PGN("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := chess.ParsePGN(exported.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := NewGameFromPGN("imported", parsed, TimeControl{Base: 300}, Rules{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reexported, err := imported.PGN("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(reexported.Plies, exported.Plies) {
+		t.Errorf("plies:\n got %v\nwant %v", reexported.Plies, exported.Plies)
+	}
+	if reexported.Result != exported.Result {
+		t.Errorf("result = %s, want %s", reexported.Result, exported.Result)
+	}
+	want, _ := g.GetState("")
+	got, _ := imported.GetState("")
+	if got.FEN != want.FEN || *got.Resolve != *want.Resolve || got.Players.White.ID != "white" || got.Players.Black.ID != "black" {
+		t.Errorf("imported %s %q %s-%s, want %s %q white-black", got.FEN, *got.Resolve, got.Players.White.ID, got.Players.Black.ID, want.FEN, *want.Resolve)
+	}
+}
+
+func TestPGNImportRejects(t *testing.T) {
+	const players = "[White \"white\"]\n[Black \"black\"]\n"
+	tests := []struct {
+		name string
+		tags string
+		text string
+		err  string
+	}{
+		{"illegal move", players, "1. e4 {[%mine a6]} e5 {[%mine a3]} 2. Ke3 *", "ply 3"},
+		{"mine under the mover's own piece", players, "1. e4 {[%mine e4]} *", "ply 1"},
+		{"mine under the king", players, "1. e4 {[%mine e8]} *", "ply 1"},
+		{"a ply without a mine before the last", players, "1. e4 e5 {[%mine a3]} *", "ply 1"},
+		{"a finished game's last ply without a mine", players, "1. e4 {[%mine a6]} e5 1-0", "ply 2"},
+		{"moves without players", "", "1. e4 {[%mine a6]} *", "needs both players"},
+		{"another variant", players + "[Variant \"Atomic\"]\n", "1. e4 {[%mine a6]} *", "unsupported variant"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := chess.ParsePGN(tt.tags + "\n" + tt.text)
+			if err == nil {
+				_, err = NewGameFromPGN("imported", parsed, TimeControl{Base: 300}, Rules{})
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v, want an error about %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// An export of a game in progress hides the armed mine. Imported, play goes on
+// from there under the game's rules, with the clock of the side to move running.
+func TestPGNImportOfGameInProgress(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	mustMove(t, g, "white", move(4, 6, 4, 4, Position{X: 0, Y: 2})) // e4
+	mustMove(t, g, "black", move(3, 1, 3, 3, Position{X: 0, Y: 5})) // d5
+	exported, err := g.PGN("white")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := chess.ParsePGN(exported.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := NewGameFromPGN("imported", parsed, TimeControl{Base: 300}, Rules{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer imported.Resign("white")
+
+	state, _ := imported.GetState("white")
+	if state.Status != GameStatusInProgress || state.ToMove != "white" {
+		t.Fatalf("imported game is %s with %s to move, want in progress with white to move", state.Status, state.ToMove)
+	}
+	if !imported.Snapshot().WhiteClock.IsRunning || imported.flagTimer == nil {
+		t.Error("white's clock isn't running")
+	}
+	if state.FirstMoveDeadline != nil {
+		t.Error("both sides have moved, but there's a first move deadline")
+	}
+	// the rules of the import apply from here on
+	if err := imported.MakeMove("white", WSMove{From: Position{X: 3, Y: 6}, To: Position{X: 3, Y: 4}}); err == nil {
+		t.Error("a move without a mine was accepted")
+	}
+}
+
+func TestPGNImportOfUnstartedGameWaitsForMoves(t *testing.T) {
+	parsed, err := chess.ParsePGN("[White \"white\"]\n[Black \"black\"]\n\n*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := NewGameFromPGN("imported", parsed, TimeControl{Base: 300}, Rules{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer imported.Resign("white")
+
+	state, _ := imported.GetState("white")
+	if state.Status != GameStatusInProgress || state.FirstMoveDeadline == nil {
+		t.Errorf("imported game is %s, first move deadline %v, want in progress with a deadline", state.Status, state.FirstMoveDeadline)
+	}
+}
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"time"
+
 	"github.com/gofiber/websocket/v2"
 )
 
@@ -12,9 +14,11 @@ type Player struct {
 }
 
 type ClientPlayer struct {
-	ID       string `json:"name"`
-	Color    string `json:"color"`
-	TimeLeft int    `json:"timeLeft"`
+	ID          string     `json:"name"`
+	Color       string     `json:"color"`
+	TimeLeft    int        `json:"timeLeft"`
+	Connected   bool       `json:"connected"`
+	ReconnectBy *time.Time `json:"reconnectBy"` // set while disconnected; after it the opponent may claim the game
 }
 
 type PlayerColor string
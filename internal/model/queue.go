@@ -12,8 +12,9 @@ type MatchFoundEvent struct {
 }
 
 type QueuedPlayer struct {
-	Player   Player
-	JoinedAt time.Time
+	Player      Player
+	TimeControl TimeControl
+	JoinedAt    time.Time
 }
 
 type Queue struct {
@@ -27,7 +28,7 @@ func NewQueue() *Queue {
 	}
 }
 
-func (q *Queue) AddPlayer(player Player) error {
+func (q *Queue) AddPlayer(player Player, timeControl TimeControl) error {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
@@ -38,26 +39,39 @@ func (q *Queue) AddPlayer(player Player) error {
 	}
 
 	qp := QueuedPlayer{
-		Player:   player,
-		JoinedAt: time.Now(),
+		Player:      player,
+		TimeControl: timeControl,
+		JoinedAt:    time.Now(),
 	}
 	q.players = append(q.players, qp)
 	return nil
 }
 
-// GetNextPair finds two players to match together
-func (q *Queue) GetNextPair() (Player, Player) {
+// GetNextPair finds two players asking for the same clock to match together.
+// First-move deadlines don't keep players apart; the game gets the defaults.
+func (q *Queue) GetNextPair() (Player, Player, TimeControl, bool) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
 	// For now, just match the two players who have been waiting longest
-	player1 := q.players[0].Player
-	player2 := q.players[1].Player
+	for i := 0; i < len(q.players); i++ {
+		for j := i + 1; j < len(q.players); j++ {
+			if q.players[i].TimeControl.Clock() != q.players[j].TimeControl.Clock() {
+				continue
+			}
+			player1 := q.players[i].Player
+			player2 := q.players[j].Player
+			timeControl := q.players[i].TimeControl.Clock()
 
-	// Remove these players from the queue
-	q.players = q.players[2:]
+			// Remove these players from the queue
+			q.players = append(q.players[:j], q.players[j+1:]...)
+			q.players = append(q.players[:i], q.players[i+1:]...)
 
-	return player1, player2
+			return player1, player2, timeControl, true
+		}
+	}
+
+	return Player{}, Player{}, TimeControl{}, false
 }
 
 func (q *Queue) Size() int {
@@ -0,0 +1,35 @@
+package model
+
+import "testing"
+
+func TestGetNextPairMatchesOnClock(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    TimeControl
+		matched bool
+	}{
+		{"same clock", TimeControl{Base: 300, Increment: 2}, TimeControl{Base: 300, Increment: 2}, true},
+		{"different first-move deadlines", TimeControl{Base: 300, FirstMoveWhite: 60}, TimeControl{Base: 300, FirstMoveBlack: 10}, true},
+		{"different base", TimeControl{Base: 300}, TimeControl{Base: 600}, false},
+		{"different increment", TimeControl{Base: 300, Increment: 2}, TimeControl{Base: 300}, false},
+		{"different delay", TimeControl{Base: 300, Delay: 2, DelayType: DelaySimple}, TimeControl{Base: 300, Delay: 2, DelayType: DelayBronstein}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewQueue()
+			if err := q.AddPlayer(Player{ID: "a"}, tt.a); err != nil {
+				t.Fatal(err)
+			}
+			if err := q.AddPlayer(Player{ID: "b"}, tt.b); err != nil {
+				t.Fatal(err)
+			}
+			_, _, timeControl, ok := q.GetNextPair()
+			if ok != tt.matched {
+				t.Fatalf("matched = %v, want %v", ok, tt.matched)
+			}
+			if ok && (timeControl.FirstMoveWhite != 0 || timeControl.FirstMoveBlack != 0) {
+				t.Errorf("matched game keeps a player's deadlines: %+v", timeControl)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"time"
+
+	"github.com/benbeisheim/minechess-backend/pkg/utils/chess"
+)
+
+// Rules are the variant and fair play settings a game is created with. The zero value is
+// the standard game, so games saved before a rule existed keep playing the
+// same way.
+type Rules struct {
+	MineOptional      bool `json:"mineOptional"`      // a move may be made without placing a mine
+	MinesUnderPieces  bool `json:"minesUnderPieces"`  // mines may go under enemy pieces other than the king
+	NoMinesNearKing   bool `json:"noMinesNearKing"`   // mines may not go next to the enemy king
+	CastlingRookBlast bool `json:"castlingRookBlast"` // a castling rook sets off a mine where it lands
+	DisconnectGrace   int  `json:"disconnectGrace"`   // seconds a disconnected player has to come back, 0 for the default
+	SpectatorDelay    int  `json:"spectatorDelay"`    // seconds spectators see the game behind the players
+	Takebacks         bool `json:"takebacks"`         // players may agree to take back moves
+}
+
+func (r Rules) spectatorDelay() time.Duration {
+	return time.Duration(r.SpectatorDelay) * time.Second
+}
+
+func (r Rules) disconnectGrace() time.Duration {
+	if r.DisconnectGrace > 0 {
+		return time.Duration(r.DisconnectGrace) * time.Second
+	}
+	return defaultDisconnectGrace
+}
+
+func (r Rules) chessOptions() chess.Options {
+	return chess.Options{RookDetonatesMine: r.CastlingRookBlast}
+}
+
+// validateMine checks the mine placed with a move against the rules. next is
+// the position after the move, so the mover's pieces are where they end up.
+func (r Rules) validateMine(next chess.Position, mine *Position) error {
+	if mine == nil {
+		if r.MineOptional {
+			return nil
+		}
+		return newGameError(ErrorCodeInvalidMine, "a mine must be placed with every move")
+	}
+	if !isValidPosition(*mine) {
+		return newGameError(ErrorCodeInvalidMine, "mine square (%d, %d) is off the board", mine.X, mine.Y)
+	}
+
+	square := mine.toSquare()
+	mover := next.ToMove.Opponent()
+	if piece := next.Board.At(square); !piece.IsEmpty() {
+		switch {
+		case piece.Color == mover:
+			return newGameError(ErrorCodeInvalidMine, "cannot place a mine under your own %s on %s", piece.Type, square)
+		case piece.Type == chess.King:
+			return newGameError(ErrorCodeInvalidMine, "cannot place a mine under the king on %s", square)
+		case !r.MinesUnderPieces:
+			return newGameError(ErrorCodeInvalidMine, "cannot place a mine under the %s on %s", piece.Type, square)
+		}
+	}
+
+	if r.NoMinesNearKing {
+		if king, ok := next.KingSquare(next.ToMove); ok && abs(king.X-square.X) <= 1 && abs(king.Y-square.Y) <= 1 {
+			return newGameError(ErrorCodeInvalidMine, "cannot place a mine next to the enemy king on %s", king)
+		}
+	}
+	return nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
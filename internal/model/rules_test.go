@@ -0,0 +1,52 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+// The mine white places with e4, checked against each rule
+func TestMovesValidateMine(t *testing.T) {
+	square := func(x, y int) *Position { return &Position{X: x, Y: y} }
+	tests := []struct {
+		name  string
+		mine  *Position
+		rules Rules
+		ok    bool
+	}{
+		{"empty square", square(0, 2), Rules{}, true},
+		{"no mine", nil, Rules{}, false},
+		{"no mine when optional", nil, Rules{MineOptional: true}, true},
+		{"off the board", square(8, 2), Rules{}, false},
+		{"under the moved pawn", square(4, 4), Rules{}, false},
+		{"under an own piece", square(3, 6), Rules{}, false},
+		{"under an enemy pawn", square(3, 1), Rules{}, false},
+		{"under an enemy pawn when allowed", square(3, 1), Rules{MinesUnderPieces: true}, true},
+		{"under the enemy king", square(4, 0), Rules{MinesUnderPieces: true}, false},
+		{"next to the enemy king", square(5, 1), Rules{MinesUnderPieces: true}, true},
+		{"next to the enemy king when not allowed", square(5, 1), Rules{MinesUnderPieces: true, NoMinesNearKing: true}, false},
+		{"away from the enemy king when not allowed next to it", square(0, 2), Rules{NoMinesNearKing: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newStartedGame(t, tt.rules)
+			err := g.MakeMove("white", WSMove{From: Position{X: 4, Y: 6}, To: Position{X: 4, Y: 4}, Mine: tt.mine})
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("got %v, want the move", err)
+				}
+				if state, _ := g.GetState("white"); (state.Mine == nil) != (tt.mine == nil) || (tt.mine != nil && *state.Mine != *tt.mine) {
+					t.Errorf("armed mine %v, want %v", state.Mine, tt.mine)
+				}
+				return
+			}
+			var gameErr *GameError
+			if !errors.As(err, &gameErr) || gameErr.Code != ErrorCodeInvalidMine || gameErr.Message == "" {
+				t.Fatalf("got %v, want an invalid mine", err)
+			}
+			if state, _ := g.GetState("white"); state.ToMove != "white" {
+				t.Error("the move was played with an invalid mine")
+			}
+		})
+	}
+}
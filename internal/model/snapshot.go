@@ -0,0 +1,156 @@
+package model
+
+import (
+	"time"
+)
+
+// GameSnapshot is everything needed to rebuild a Game, including the parts
+// hidden from clients such as the armed mine
+type GameSnapshot struct {
+	ID          string                   `json:"id"`
+	State       GameState                `json:"state"`
+	Mine        *Position                `json:"mine"`
+	TimeControl TimeControl              `json:"timeControl"`
+	WhiteClock  ClockSnapshot            `json:"whiteClock"`
+	BlackClock  ClockSnapshot            `json:"blackClock"`
+	DrawOffer   *DrawOffer               `json:"drawOffer"`
+	Mines       []*Position              `json:"mines"`
+	StartFEN    string                   `json:"startFen,omitempty"`
+	Positions   []uint64                 `json:"positions"`
+	Seq         uint64                   `json:"seq"`
+	Requests    map[string][]moveRequest `json:"requests,omitempty"`
+	Undo        []undoPoint              `json:"undo,omitempty"`
+	CreatedAt   time.Time                `json:"createdAt"`
+	StartedAt   time.Time                `json:"startedAt"`
+	EndedAt     time.Time                `json:"endedAt"`
+	SavedAt     time.Time                `json:"savedAt"`
+}
+
+type ClockSnapshot struct {
+	TimeLeft  time.Duration `json:"timeLeft"`
+	IsRunning bool          `json:"isRunning"`
+}
+
+func (s GameSnapshot) IsFinished() bool {
+	return s.State.Status.IsOver() || s.State.Resolve != nil
+}
+
+func (s GameSnapshot) HasPlayer(playerID string) bool {
+	return playerID != "" && (s.State.Players.White.ID == playerID || s.State.Players.Black.ID == playerID)
+}
+
+func (c *Clock) snapshot() ClockSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	timeLeft := c.timeLeft
+	if c.isRunning {
+		timeLeft = c.remaining(time.Since(c.lastStarted))
+	}
+	return ClockSnapshot{TimeLeft: timeLeft, IsRunning: c.isRunning}
+}
+
+// restoreClock rebuilds a clock from a snapshot. A clock that was running is
+// restarted now, so time spent while the server was down isn't charged.
+func restoreClock(timeControl TimeControl, s ClockSnapshot) *Clock {
+	clock := NewClock(timeControl)
+	clock.timeLeft = s.TimeLeft
+	if s.IsRunning {
+		clock.Start()
+	}
+	return clock
+}
+
+// Snapshot returns a copy of the game that can be stored and restored later.
+// It shares nothing the game changes, so it may be stored after the game has
+// moved on.
+func (g *Game) Snapshot() GameSnapshot {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.snapshot()
+}
+
+func (g *Game) snapshot() GameSnapshot {
+	var mine *Position
+	if g.mine != nil {
+		mineCopy := *g.mine
+		mine = &mineCopy
+	}
+	var drawOffer *DrawOffer
+	if g.drawOffer != nil {
+		offerCopy := *g.drawOffer
+		drawOffer = &offerCopy
+	}
+	return GameSnapshot{
+		ID:          g.ID,
+		State:       g.state.clone(),
+		Mine:        mine,
+		TimeControl: g.timeControl,
+		WhiteClock:  g.whiteClock.snapshot(),
+		BlackClock:  g.blackClock.snapshot(),
+		DrawOffer:   drawOffer,
+		Mines:       append([]*Position{}, g.mines...),
+		StartFEN:    g.startFEN,
+		Positions:   append([]uint64{}, g.positions...),
+		Seq:         g.seq,
+		Requests:    copyRequests(g.requests),
+		Undo:        append([]undoPoint(nil), g.undo...),
+		CreatedAt:   g.createdAt,
+		StartedAt:   g.startedAt,
+		EndedAt:     g.endedAt,
+		SavedAt:     time.Now(),
+	}
+}
+
+// RestoreGame rebuilds a game from a snapshot and restarts the flag timer if a
+// clock was running
+func RestoreGame(s GameSnapshot) *Game {
+	g := &Game{
+		ID:          s.ID,
+		state:       s.State,
+		connections: NewGameConnections(),
+		mine:        s.Mine,
+		timeControl: s.TimeControl,
+		whiteClock:  restoreClock(s.TimeControl, s.WhiteClock),
+		blackClock:  restoreClock(s.TimeControl, s.BlackClock),
+		drawOffer:   s.DrawOffer,
+		mines:       s.Mines,
+		startFEN:    s.StartFEN,
+		positions:   s.Positions,
+		seq:         s.Seq,
+		requests:    s.Requests,
+		undo:        s.Undo,
+		createdAt:   s.CreatedAt,
+		startedAt:   s.StartedAt,
+		endedAt:     s.EndedAt,
+	}
+	if g.state.Status == "" {
+		g.state.Status = statusOf(s.State)
+	}
+	if g.state.Status == GameStatusInProgress && (s.WhiteClock.IsRunning || s.BlackClock.IsRunning) {
+		g.armFlagTimer()
+	}
+	// like the clocks, the first move deadline starts over after a restart
+	g.armFirstMoveTimer()
+	g.markAllDisconnected()
+	return g
+}
+
+// OnCommit registers fn to be called with a snapshot after every change to the
+// game. It's called with the game locked, so fn must not call back into the
+// game, and should hand slow work such as storage off rather than do it there.
+func (g *Game) OnCommit(fn func(GameSnapshot)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.onCommit = fn
+}
+
+// commit pushes the new state to every connection and hands it to the store, if any
+func (g *Game) commit() {
+	g.broadcastState()
+	if g.onCommit != nil {
+		g.onCommit(g.snapshot())
+	}
+}
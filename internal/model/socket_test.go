@@ -0,0 +1,82 @@
+package model
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbeisheim/minechess-backend/internal/ws"
+	"github.com/gofiber/websocket/v2"
+)
+
+// fakeSocket collects what a connection writes to it
+type fakeSocket struct {
+	mu         sync.Mutex
+	written    []ws.Message
+	closeFrame []byte
+}
+
+func (s *fakeSocket) SetWriteDeadline(time.Time) error { return nil }
+
+func (s *fakeSocket) WriteJSON(v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.written = append(s.written, v.(ws.Message))
+	return nil
+}
+
+func (s *fakeSocket) WriteMessage(messageType int, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if messageType == websocket.CloseMessage {
+		s.closeFrame = data
+	}
+	return nil
+}
+
+func (s *fakeSocket) Close() error { return nil }
+
+// newConnection returns a connection writing to a fake socket. It's closed
+// when the test ends.
+func newConnection(t testing.TB) (*ws.Connection, *fakeSocket) {
+	socket := &fakeSocket{}
+	conn := ws.NewConnection(socket)
+	t.Cleanup(func() { conn.Close(websocket.CloseNormalClosure, "") })
+	return conn, socket
+}
+
+// waitFor waits until what the socket has been written satisfies ok, and
+// returns it
+func (s *fakeSocket) waitFor(t testing.TB, what string, ok func([]ws.Message) bool) []ws.Message {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		written := append([]ws.Message{}, s.written...)
+		s.mu.Unlock()
+		if ok(written) {
+			return written
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitForClose waits for the connection to send a close frame, and returns
+// its reason
+func (s *fakeSocket) waitForClose(t testing.TB) string {
+	t.Helper()
+	var reason string
+	s.waitFor(t, "a close frame", func([]ws.Message) bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if len(s.closeFrame) < 2 {
+			return false
+		}
+		reason = string(s.closeFrame[2:])
+		return true
+	})
+	return reason
+}
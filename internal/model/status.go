@@ -0,0 +1,65 @@
+package model
+
+// GameStatus is where a game is in its lifecycle. Games move forward only:
+// waiting -> inProgress -> finished, with aborted reachable from either of
+// the first two.
+type GameStatus string
+
+const (
+	GameStatusWaiting    GameStatus = "waiting"    // a seat is still empty
+	GameStatusInProgress GameStatus = "inProgress" // both players are seated
+	GameStatusFinished   GameStatus = "finished"   // the game has a result
+	GameStatusAborted    GameStatus = "aborted"    // the game ended without a result
+)
+
+var gameTransitions = map[GameStatus][]GameStatus{
+	GameStatusWaiting:    {GameStatusInProgress, GameStatusAborted},
+	GameStatusInProgress: {GameStatusFinished, GameStatusAborted},
+}
+
+// IsOver reports whether the game has ended, with or without a result
+func (s GameStatus) IsOver() bool {
+	return s == GameStatusFinished || s == GameStatusAborted
+}
+
+func (s GameStatus) canBecome(next GameStatus) bool {
+	for _, allowed := range gameTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// transition moves the game to next, reporting whether that's allowed from
+// the current status
+func (g *Game) transition(next GameStatus) bool {
+	if !g.state.Status.canBecome(next) {
+		return false
+	}
+	g.state.Status = next
+	return true
+}
+
+// requireInProgress fails unless both players are seated and the game hasn't
+// ended. Every command that changes the position or the clocks checks it.
+func (g *Game) requireInProgress() error {
+	switch {
+	case g.state.Status.IsOver():
+		return ErrGameOver
+	case g.state.Status != GameStatusInProgress:
+		return ErrNotStarted
+	}
+	return nil
+}
+
+// statusOf works out the status of a game saved before statuses were stored
+func statusOf(state GameState) GameStatus {
+	switch {
+	case state.Resolve != nil:
+		return GameStatusFinished
+	case state.Players.White.ID != "" && state.Players.Black.ID != "":
+		return GameStatusInProgress
+	}
+	return GameStatusWaiting
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGameStatusTransitions(t *testing.T) {
+	tests := []struct {
+		from, to GameStatus
+		ok       bool
+	}{
+		{GameStatusWaiting, GameStatusInProgress, true},
+		{GameStatusWaiting, GameStatusAborted, true},
+		{GameStatusWaiting, GameStatusFinished, false},
+		{GameStatusInProgress, GameStatusFinished, true},
+		{GameStatusInProgress, GameStatusAborted, true},
+		{GameStatusInProgress, GameStatusWaiting, false},
+		{GameStatusFinished, GameStatusInProgress, false},
+		{GameStatusFinished, GameStatusAborted, false},
+		{GameStatusAborted, GameStatusInProgress, false},
+	}
+	for _, tt := range tests {
+		if got := tt.from.canBecome(tt.to); got != tt.ok {
+			t.Errorf("%s -> %s allowed %v, want %v", tt.from, tt.to, got, tt.ok)
+		}
+	}
+}
+
+func TestMovesWaitForBothPlayers(t *testing.T) {
+	g := NewGame("test", TimeControl{Base: 300}, Rules{})
+	if _, err := g.AddPlayer("white"); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := g.GetState("white"); state.Status != GameStatusWaiting {
+		t.Fatalf("status %s with one player, want %s", state.Status, GameStatusWaiting)
+	}
+	if err := g.MakeMove("white", move(4, 6, 4, 4, a6)); !errors.Is(err, ErrNotStarted) {
+		t.Fatalf("got %v, want %v", err, ErrNotStarted)
+	}
+	if err := g.Resign("white"); !errors.Is(err, ErrNotStarted) {
+		t.Fatalf("resigning: got %v, want %v", err, ErrNotStarted)
+	}
+
+	if _, err := g.AddPlayer("black"); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := g.GetState("white"); state.Status != GameStatusInProgress {
+		t.Fatalf("status %s with both players, want %s", state.Status, GameStatusInProgress)
+	}
+	mustMove(t, g, "white", move(4, 6, 4, 4, a6)) // e4
+}
+
+// After checkmate nothing may change the game, and the clocks stay stopped
+func TestCommandsAfterGameOverAreRejected(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	mustMove(t, g, "white", move(5, 6, 5, 5, Position{X: 0, Y: 2})) // f3
+	mustMove(t, g, "black", move(4, 1, 4, 3, Position{X: 0, Y: 5})) // e5
+	mustMove(t, g, "white", move(6, 6, 6, 4, Position{X: 1, Y: 2})) // g4
+	mustMove(t, g, "black", move(3, 0, 7, 4, Position{X: 1, Y: 5})) // Qh4#
+
+	snapshot := g.Snapshot()
+	if snapshot.State.Status != GameStatusFinished || snapshot.State.Resolve == nil || *snapshot.State.Resolve != "black wins by Checkmate" {
+		t.Fatalf("status %s, result %v, want black to win by checkmate", snapshot.State.Status, snapshot.State.Resolve)
+	}
+	commands := map[string]func() error{
+		"move":       func() error { return g.MakeMove("white", move(0, 6, 0, 5, Position{X: 7, Y: 2})) },
+		"resign":     func() error { return g.Resign("white") },
+		"offer draw": func() error { return g.OfferDraw("white") },
+		"abort":      func() error { return g.Abort("white") },
+	}
+	for name, command := range commands {
+		if err := command(); err == nil || AsGameError(err).Code != ErrorCodeGameOver {
+			t.Errorf("%s: got %v, want the game to be over", name, err)
+		}
+	}
+	if snapshot := g.Snapshot(); snapshot.WhiteClock.IsRunning || snapshot.BlackClock.IsRunning {
+		t.Error("a clock is running after the game ended")
+	}
+}
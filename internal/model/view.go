@@ -0,0 +1,65 @@
+package model
+
+// Game state is never sent as is. Every recipient gets a view of it, so that
+// information one side isn't allowed to see can be left out. Today that's the
+// armed mine: only the player who placed it sees it, and everyone else learns
+// where it was when it explodes (Explosion) or expires (LastMine). A queued
+// premove is likewise only shown to the player who queued it.
+
+// viewFor projects the game state for playerID, who may be either player or
+// a spectator. The caller must hold g.mu.
+func (g *Game) viewFor(playerID string) GameState {
+	// a view may be sent later, or diffed against, so it mustn't share
+	// anything with the state that the next move changes
+	view := g.state.clone()
+	view.Mine = nil
+	// legal move hints are left to the client, which can't know where the mine
+	// is, so the server never sends any that might give it away
+	view.LegalMoves = make([]Position, 0)
+
+	color, seated := g.colorOf(playerID)
+	if premove, queued := g.premoves[color]; seated && queued {
+		view.Premove = &premove
+	}
+
+	if g.mine == nil {
+		return view
+	}
+	// the mine is armed by whoever just moved, against the side to move. Once
+	// the game is over there's nothing left to hide.
+	if g.state.Status.IsOver() || (seated && color != g.state.ToMove) {
+		mine := *g.mine
+		view.Mine = &mine
+	}
+	return view
+}
+
+// requireLiveView checks that playerID may see the game as it is now, rather
+// than with the spectator delay. The delay is applied to spectators' sockets
+// as they're sent to, so anywhere else in a delayed game only the players get
+// to see a game in progress. The caller must hold g.mu.
+func (g *Game) requireLiveView(playerID string) error {
+	if _, seated := g.colorOf(playerID); seated || g.state.Status.IsOver() || g.state.Rules.spectatorDelay() == 0 {
+		return nil
+	}
+	return ErrDelayed
+}
+
+// views returns the view of every connected recipient
+func (g *Game) views(playerIDs []string) map[string]GameState {
+	views := make(map[string]GameState, len(playerIDs))
+	for _, playerID := range playerIDs {
+		views[playerID] = g.viewFor(playerID)
+	}
+	return views
+}
+
+// clone copies the state deep enough that later moves don't show through:
+// the move history has its last move filled in in place, and captured pieces
+// are appended to. Everything else is replaced rather than changed.
+func (s GameState) clone() GameState {
+	s.MoveHistory = append([]Move{}, s.MoveHistory...)
+	s.CapturedPieces.White = append([]Piece{}, s.CapturedPieces.White...)
+	s.CapturedPieces.Black = append([]Piece{}, s.CapturedPieces.Black...)
+	return s
+}
@@ -0,0 +1,184 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// the mine white arms with e4, on a6
+var a6 = Position{X: 0, Y: 2}
+
+func TestViewShowsMineOnlyToItsPlacer(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	mustMove(t, g, "white", move(4, 6, 4, 4, a6)) // e4
+
+	tests := []struct {
+		playerID string
+		sees     bool
+	}{
+		{"white", true},
+		{"black", false},
+		{"spectator", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.playerID, func(t *testing.T) {
+			if got := g.viewFor(tt.playerID).Mine; (got != nil) != tt.sees || (got != nil && *got != a6) {
+				t.Errorf("viewFor mine = %v, want seen %v", got, tt.sees)
+			}
+			state, err := g.GetState(tt.playerID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := state.Mine; (got != nil) != tt.sees {
+				t.Errorf("GetState mine = %v, want seen %v", got, tt.sees)
+			}
+		})
+	}
+}
+
+// Neither the full state nor a delta sent to the opponent or a spectator may
+// carry the mine, while the placer's do
+func TestPayloadsCarryMineOnlyToItsPlacer(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	base := map[string]GameState{"white": g.viewFor("white"), "black": g.viewFor("black"), "": g.viewFor("")}
+	mustMove(t, g, "white", move(4, 6, 4, 4, a6)) // e4
+
+	for playerID, sees := range map[string]bool{"white": true, "black": false, "": false} {
+		view := g.viewFor(playerID)
+
+		msg, err := stateMessage(view, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var state GameState
+		if err := json.Unmarshal(msg.Payload, &state); err != nil {
+			t.Fatal(err)
+		}
+		if (state.Mine != nil) != sees {
+			t.Errorf("%q: full state mine = %v, want seen %v", playerID, state.Mine, sees)
+		}
+
+		msg, err = deltaMessage(sentState{seq: 1, view: base[playerID]}, view, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var delta StateDelta
+		if err := json.Unmarshal(msg.Payload, &delta); err != nil {
+			t.Fatal(err)
+		}
+		var mine *Position
+		if raw, ok := delta.Fields["mine"]; ok {
+			if err := json.Unmarshal(raw, &mine); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if (mine != nil) != sees || (mine != nil && *mine != a6) {
+			t.Errorf("%q: delta mine = %v, want seen %v", playerID, mine, sees)
+		}
+	}
+}
+
+func TestViewRevealsMineOnceGameIsOver(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	mustMove(t, g, "white", move(4, 6, 4, 4, a6)) // e4
+	base := g.viewFor("black")
+	if err := g.Resign("black"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, playerID := range []string{"white", "black", ""} {
+		if got := g.viewFor(playerID).Mine; got == nil || *got != a6 {
+			t.Errorf("%q: mine = %v, want %v", playerID, got, a6)
+		}
+	}
+	delta, err := diffState(base, g.viewFor("black"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := delta.Fields["mine"]; !ok {
+		t.Errorf("delta to the opponent doesn't reveal the mine: %v", delta.Fields)
+	}
+}
+
+func TestViewShowsPremoveOnlyToItsOwner(t *testing.T) {
+	g := newStartedGame(t, Rules{})
+	mustMove(t, g, "white", move(4, 6, 4, 4, a6))                                      // e4
+	if err := g.Premove("white", move(3, 6, 3, 4, Position{X: 7, Y: 2})); err != nil { // d4
+		t.Fatal(err)
+	}
+
+	if g.viewFor("white").Premove == nil {
+		t.Error("the premove isn't shown to its owner")
+	}
+	for _, playerID := range []string{"black", ""} {
+		if premove := g.viewFor(playerID).Premove; premove != nil {
+			t.Errorf("%q sees the premove %v", playerID, premove)
+		}
+	}
+}
+
+// With a spectator delay, only the players see a game in progress outside the
+// socket, where the delay is applied
+func TestDelayedGameIsOnlyLiveForPlayers(t *testing.T) {
+	g := newStartedGame(t, Rules{SpectatorDelay: 30})
+	mustMove(t, g, "white", move(4, 6, 4, 4, Position{X: 0, Y: 2})) // e4
+
+	for _, playerID := range []string{"white", "black"} {
+		if _, err := g.GetState(playerID); err != nil {
+			t.Errorf("GetState(%q): %v", playerID, err)
+		}
+		if _, err := g.PGN(playerID); err != nil {
+			t.Errorf("PGN(%q): %v", playerID, err)
+		}
+	}
+	if _, err := g.GetState("spectator"); !errors.Is(err, ErrDelayed) {
+		t.Errorf("GetState for a spectator: got %v, want ErrDelayed", err)
+	}
+	if _, err := g.PGN(""); !errors.Is(err, ErrDelayed) {
+		t.Errorf("PGN for a spectator: got %v, want ErrDelayed", err)
+	}
+
+	if err := g.Resign("black"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.GetState("spectator"); err != nil {
+		t.Errorf("GetState for a spectator once the game is over: %v", err)
+	}
+	if _, err := g.PGN(""); err != nil {
+		t.Errorf("PGN for a spectator once the game is over: %v", err)
+	}
+}
+
+// Only players get a live connection. Connecting while a seat is open takes
+// it; once the game is full, anyone else is turned away to watch with the
+// spectator delay.
+func TestRegisterConnectionIsForPlayersOnly(t *testing.T) {
+	g := NewGame("test", TimeControl{Base: 300}, Rules{SpectatorDelay: 30})
+	if _, err := g.AddPlayer("white"); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, _ := newConnection(t)
+	if err := g.RegisterConnection("black", conn); err != nil {
+		t.Fatal(err)
+	}
+	state, err := g.GetState("black")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Players.Black.ID != "black" || state.Status != GameStatusInProgress {
+		t.Fatalf("connecting didn't take the open seat: %+v, %s", state.Players, state.Status)
+	}
+
+	lurker, _ := newConnection(t)
+	if err := g.RegisterConnection("lurker", lurker); !errors.Is(err, ErrNotInGame) {
+		t.Errorf("got %v, want ErrNotInGame", err)
+	}
+	g.connections.mu.RLock()
+	defer g.connections.mu.RUnlock()
+	if _, ok := g.connections.connections["lurker"]; ok {
+		t.Error("a non-player was registered as a player connection")
+	}
+}
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"github.com/benbeisheim/minechess-backend/internal/model"
+)
+
+// ArchiveFilter narrows down and pages a player's finished games
+type ArchiveFilter struct {
+	Result model.PlayerResult // empty for any result
+	Offset int
+	Limit  int
+}
+
+// ArchiveRepository stores finished games for game history
+type ArchiveRepository interface {
+	SaveArchive(game model.ArchivedGame) error
+	LoadArchive(gameID string) (model.ArchivedGame, error)
+	// ListArchivesByPlayer returns one page of the player's games, most recently
+	// finished first, along with the total number of games matching the filter
+	ListArchivesByPlayer(playerID string, filter ArchiveFilter) ([]model.ArchivedGame, int, error)
+}
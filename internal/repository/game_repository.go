@@ -1 +1,24 @@
 package repository
+
+import (
+	"errors"
+
+	"github.com/benbeisheim/minechess-backend/internal/model"
+)
+
+var ErrGameNotFound = errors.New("game not found")
+
+// GameRepository stores game snapshots so games survive a restart
+type GameRepository interface {
+	// Save inserts or replaces the game with the snapshot's ID
+	Save(snapshot model.GameSnapshot) error
+	Load(gameID string) (model.GameSnapshot, error)
+	// ListByPlayer returns the player's games, most recently updated first
+	ListByPlayer(playerID string) ([]model.GameSnapshot, error)
+	// ListActive returns every game that hasn't finished yet
+	ListActive() ([]model.GameSnapshot, error)
+	// ListFinished returns every finished game still stored, which only happens
+	// when it couldn't be archived before the server stopped
+	ListFinished() ([]model.GameSnapshot, error)
+	Delete(gameID string) error
+}
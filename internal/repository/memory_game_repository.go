@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/benbeisheim/minechess-backend/internal/model"
+)
+
+// MemoryGameRepository keeps games in memory. Snapshots are stored encoded so
+// callers can't alias state held by a live Game.
+type MemoryGameRepository struct {
+	games    map[string][]byte
+	archives map[string][]byte
+	mu       sync.RWMutex
+}
+
+func NewMemoryGameRepository() *MemoryGameRepository {
+	return &MemoryGameRepository{
+		games:    make(map[string][]byte),
+		archives: make(map[string][]byte),
+	}
+}
+
+func (r *MemoryGameRepository) Save(snapshot model.GameSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.games[snapshot.ID] = data
+	return nil
+}
+
+func (r *MemoryGameRepository) Load(gameID string) (model.GameSnapshot, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	data, exists := r.games[gameID]
+	if !exists {
+		return model.GameSnapshot{}, ErrGameNotFound
+	}
+	var snapshot model.GameSnapshot
+	err := json.Unmarshal(data, &snapshot)
+	return snapshot, err
+}
+
+func (r *MemoryGameRepository) ListByPlayer(playerID string) ([]model.GameSnapshot, error) {
+	return r.list(func(s model.GameSnapshot) bool { return s.HasPlayer(playerID) })
+}
+
+func (r *MemoryGameRepository) ListActive() ([]model.GameSnapshot, error) {
+	return r.list(func(s model.GameSnapshot) bool { return !s.IsFinished() })
+}
+
+func (r *MemoryGameRepository) ListFinished() ([]model.GameSnapshot, error) {
+	return r.list(func(s model.GameSnapshot) bool { return s.IsFinished() })
+}
+
+func (r *MemoryGameRepository) Delete(gameID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.games, gameID)
+	return nil
+}
+
+func (r *MemoryGameRepository) list(keep func(model.GameSnapshot) bool) ([]model.GameSnapshot, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	snapshots := []model.GameSnapshot{}
+	for _, data := range r.games {
+		var snapshot model.GameSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return nil, err
+		}
+		if keep(snapshot) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].SavedAt.After(snapshots[j].SavedAt)
+	})
+	return snapshots, nil
+}
+
+func (r *MemoryGameRepository) SaveArchive(game model.ArchivedGame) error {
+	data, err := json.Marshal(game)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.archives[game.ID] = data
+	return nil
+}
+
+func (r *MemoryGameRepository) LoadArchive(gameID string) (model.ArchivedGame, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	data, exists := r.archives[gameID]
+	if !exists {
+		return model.ArchivedGame{}, ErrGameNotFound
+	}
+	var game model.ArchivedGame
+	err := json.Unmarshal(data, &game)
+	return game, err
+}
+
+func (r *MemoryGameRepository) ListArchivesByPlayer(playerID string, filter ArchiveFilter) ([]model.ArchivedGame, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	games := []model.ArchivedGame{}
+	for _, data := range r.archives {
+		var game model.ArchivedGame
+		if err := json.Unmarshal(data, &game); err != nil {
+			return nil, 0, err
+		}
+		if game.White != playerID && game.Black != playerID {
+			continue
+		}
+		if filter.Result != "" && game.ResultFor(playerID) != filter.Result {
+			continue
+		}
+		games = append(games, game)
+	}
+	sort.Slice(games, func(i, j int) bool {
+		return games[i].EndedAt.After(games[j].EndedAt)
+	})
+
+	total := len(games)
+	start := min(filter.Offset, total)
+	end := min(start+filter.Limit, total)
+	return games[start:end], total, nil
+}
@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/benbeisheim/minechess-backend/internal/model"
+	_ "modernc.org/sqlite"
+)
+
+const gamesSchema = `
+CREATE TABLE IF NOT EXISTS games (
+	id         TEXT PRIMARY KEY,
+	white_id   TEXT NOT NULL,
+	black_id   TEXT NOT NULL,
+	finished   INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL,
+	snapshot   TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS games_white_id ON games (white_id);
+CREATE INDEX IF NOT EXISTS games_black_id ON games (black_id);
+CREATE INDEX IF NOT EXISTS games_finished ON games (finished);
+
+CREATE TABLE IF NOT EXISTS archived_games (
+	id       TEXT PRIMARY KEY,
+	white_id TEXT NOT NULL,
+	black_id TEXT NOT NULL,
+	winner   TEXT NOT NULL,
+	ended_at INTEGER NOT NULL,
+	game     TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS archived_games_white_id ON archived_games (white_id, ended_at);
+CREATE INDEX IF NOT EXISTS archived_games_black_id ON archived_games (black_id, ended_at);
+`
+
+// SQLiteGameRepository stores each game as a JSON snapshot in an embedded
+// SQLite database, with the columns we query on pulled out alongside it
+type SQLiteGameRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteGameRepository(path string) (*SQLiteGameRepository, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// SQLite only allows a single writer
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(gamesSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+	return &SQLiteGameRepository{db: db}, nil
+}
+
+func (r *SQLiteGameRepository) Close() error {
+	return r.db.Close()
+}
+
+func (r *SQLiteGameRepository) Save(snapshot model.GameSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`
+		INSERT INTO games (id, white_id, black_id, finished, updated_at, snapshot)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			white_id = excluded.white_id,
+			black_id = excluded.black_id,
+			finished = excluded.finished,
+			updated_at = excluded.updated_at,
+			snapshot = excluded.snapshot`,
+		snapshot.ID,
+		snapshot.State.Players.White.ID,
+		snapshot.State.Players.Black.ID,
+		snapshot.IsFinished(),
+		snapshot.SavedAt.UnixNano(),
+		string(data),
+	)
+	return err
+}
+
+func (r *SQLiteGameRepository) Load(gameID string) (model.GameSnapshot, error) {
+	var data string
+	err := r.db.QueryRow(`SELECT snapshot FROM games WHERE id = ?`, gameID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.GameSnapshot{}, ErrGameNotFound
+	}
+	if err != nil {
+		return model.GameSnapshot{}, err
+	}
+	var snapshot model.GameSnapshot
+	err = json.Unmarshal([]byte(data), &snapshot)
+	return snapshot, err
+}
+
+func (r *SQLiteGameRepository) ListByPlayer(playerID string) ([]model.GameSnapshot, error) {
+	return r.query(`SELECT snapshot FROM games WHERE white_id = ? OR black_id = ? ORDER BY updated_at DESC`, playerID, playerID)
+}
+
+func (r *SQLiteGameRepository) ListActive() ([]model.GameSnapshot, error) {
+	return r.query(`SELECT snapshot FROM games WHERE finished = 0 ORDER BY updated_at DESC`)
+}
+
+func (r *SQLiteGameRepository) ListFinished() ([]model.GameSnapshot, error) {
+	return r.query(`SELECT snapshot FROM games WHERE finished = 1 ORDER BY updated_at DESC`)
+}
+
+func (r *SQLiteGameRepository) Delete(gameID string) error {
+	_, err := r.db.Exec(`DELETE FROM games WHERE id = ?`, gameID)
+	return err
+}
+
+func (r *SQLiteGameRepository) query(query string, args ...interface{}) ([]model.GameSnapshot, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []model.GameSnapshot{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var snapshot model.GameSnapshot
+		if err := json.Unmarshal([]byte(data), &snapshot); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
+
+func (r *SQLiteGameRepository) SaveArchive(game model.ArchivedGame) error {
+	data, err := json.Marshal(game)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`
+		INSERT OR REPLACE INTO archived_games (id, white_id, black_id, winner, ended_at, game)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		game.ID, game.White, game.Black, game.Winner, game.EndedAt.UnixNano(), string(data),
+	)
+	return err
+}
+
+func (r *SQLiteGameRepository) LoadArchive(gameID string) (model.ArchivedGame, error) {
+	var data string
+	err := r.db.QueryRow(`SELECT game FROM archived_games WHERE id = ?`, gameID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ArchivedGame{}, ErrGameNotFound
+	}
+	if err != nil {
+		return model.ArchivedGame{}, err
+	}
+	var game model.ArchivedGame
+	err = json.Unmarshal([]byte(data), &game)
+	return game, err
+}
+
+func (r *SQLiteGameRepository) ListArchivesByPlayer(playerID string, filter ArchiveFilter) ([]model.ArchivedGame, int, error) {
+	where := `(white_id = ? OR black_id = ?)`
+	args := []interface{}{playerID, playerID}
+	switch filter.Result {
+	case model.PlayerResultWin:
+		where += ` AND ((winner = 'white' AND white_id = ?) OR (winner = 'black' AND black_id = ?))`
+		args = append(args, playerID, playerID)
+	case model.PlayerResultLoss:
+		where += ` AND ((winner = 'white' AND black_id = ?) OR (winner = 'black' AND white_id = ?))`
+		args = append(args, playerID, playerID)
+	case model.PlayerResultDraw:
+		where += ` AND winner = ''`
+	}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM archived_games WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(`SELECT game FROM archived_games WHERE `+where+` ORDER BY ended_at DESC LIMIT ? OFFSET ?`,
+		append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	games := []model.ArchivedGame{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, 0, err
+		}
+		var game model.ArchivedGame
+		if err := json.Unmarshal([]byte(data), &game); err != nil {
+			return nil, 0, err
+		}
+		games = append(games, game)
+	}
+	return games, total, rows.Err()
+}
@@ -9,12 +9,23 @@ import (
 	"time"
 
 	"github.com/benbeisheim/minechess-backend/internal/model"
-	"github.com/gofiber/websocket/v2"
+	"github.com/benbeisheim/minechess-backend/internal/repository"
+	"github.com/benbeisheim/minechess-backend/internal/ws"
+	"github.com/benbeisheim/minechess-backend/pkg/utils/chess"
 	"github.com/google/uuid"
 )
 
+// How long a finished game stays live after it has been archived
+const finishedGameRetention = 10 * time.Minute
+
 type GameManager struct {
 	games            map[string]*model.Game
+	repo             repository.GameRepository
+	archive          repository.ArchiveRepository
+	archived         map[string]bool // ended games waiting to be evicted
+	archiveMu        sync.Mutex
+	writers          map[string]*gameWriter // game ID -> the writer saving it
+	writersMu        sync.Mutex
 	queue            *model.Queue
 	matchingChannels map[string]chan string
 	mu               sync.RWMutex
@@ -46,13 +57,12 @@ func (gm *GameManager) processMatchmaking() {
 
 	for range ticker.C {
 		gm.mu.Lock()
-		if gm.queue.Size() >= 2 {
-			player1, player2 := gm.queue.GetNextPair()
-
+		if player1, player2, timeControl, ok := gm.queue.GetNextPair(); ok {
 			// Create and set up the game as before...
 			// Create new game
 			gameID := uuid.New().String()
-			game := model.NewGame(gameID)
+			game := model.NewGame(gameID, timeControl, model.Rules{})
+			gm.track(game)
 
 			// Add players to game
 			p1Color, err := game.AddPlayer(player1.ID) // Assuming this returns the assigned color
@@ -138,20 +148,29 @@ func mustJSON(v interface{}) string {
 	return string(bytes)
 }
 
-func NewGameManager() *GameManager {
+func NewGameManager(repo repository.GameRepository, archive repository.ArchiveRepository) *GameManager {
 	gm := &GameManager{
 		games:            make(map[string]*model.Game),
+		repo:             repo,
+		archive:          archive,
+		archived:         make(map[string]bool),
+		writers:          make(map[string]*gameWriter),
 		queue:            model.NewQueue(),
 		matchingChannels: make(map[string]chan string),
 	}
 
+	if err := gm.restoreGames(); err != nil {
+		fmt.Println("Error restoring games", err)
+	}
+
 	// Start matchmaking processor
 	go gm.processMatchmaking()
 
 	return gm
 }
 
-func (gm *GameManager) CreateGame(gameID string) error {
+// CreateGame starts a game from the standard position, or from fen if it's set
+func (gm *GameManager) CreateGame(gameID string, timeControl model.TimeControl, fen string, rules model.Rules) error {
 	gm.mu.Lock()
 	defer gm.mu.Unlock()
 
@@ -159,7 +178,163 @@ func (gm *GameManager) CreateGame(gameID string) error {
 		return errors.New("game already exists")
 	}
 
-	gm.games[gameID] = model.NewGame(gameID)
+	game := model.NewGame(gameID, timeControl, rules)
+	if fen != "" {
+		var err error
+		if game, err = model.NewGameFromFEN(gameID, fen, timeControl, rules); err != nil {
+			return err
+		}
+	}
+	gm.track(game)
+	gm.games[gameID] = game
+	return nil
+}
+
+// track saves the game now and after every change from here on
+func (gm *GameManager) track(game *model.Game) {
+	writer := gm.startWriter(game.ID)
+	game.OnCommit(writer.push)
+	writer.push(game.Snapshot())
+}
+
+// startWriter starts the goroutine that saves gameID's snapshots
+func (gm *GameManager) startWriter(gameID string) *gameWriter {
+	writer := newGameWriter(gm.saveGame)
+	gm.writersMu.Lock()
+	defer gm.writersMu.Unlock()
+	if old, exists := gm.writers[gameID]; exists {
+		old.close()
+	}
+	gm.writers[gameID] = writer
+	return writer
+}
+
+func (gm *GameManager) stopWriter(gameID string) {
+	gm.writersMu.Lock()
+	defer gm.writersMu.Unlock()
+	if writer, exists := gm.writers[gameID]; exists {
+		writer.close()
+		delete(gm.writers, gameID)
+	}
+}
+
+// saveGame runs on the game's writer, off the game's and the manager's locks.
+// Only games in progress are kept for crash recovery; ended games are archived
+// or dropped.
+func (gm *GameManager) saveGame(snapshot model.GameSnapshot) {
+	switch {
+	case snapshot.State.Status == model.GameStatusAborted:
+		// aborted games leave no record
+		if err := gm.repo.Delete(snapshot.ID); err != nil {
+			fmt.Println("Error deleting aborted game", snapshot.ID, err)
+		}
+		gm.retireGame(snapshot.ID)
+	case snapshot.IsFinished():
+		gm.archiveGame(snapshot)
+	default:
+		if err := gm.repo.Save(snapshot); err != nil {
+			fmt.Println("Error saving game", snapshot.ID, err)
+		}
+	}
+}
+
+// archiveGame moves a finished game into the archive before retiring it
+func (gm *GameManager) archiveGame(snapshot model.GameSnapshot) {
+	if err := gm.storeArchive(snapshot); err != nil {
+		fmt.Println("Error archiving game", snapshot.ID, err)
+		return
+	}
+	gm.retireGame(snapshot.ID)
+}
+
+// storeArchive archives a finished game and deletes its live row. If it can't
+// be archived, the row is saved instead, to be archived on the next start.
+func (gm *GameManager) storeArchive(snapshot model.GameSnapshot) error {
+	if err := gm.archive.SaveArchive(model.NewArchivedGame(snapshot)); err != nil {
+		if saveErr := gm.repo.Save(snapshot); saveErr != nil {
+			fmt.Println("Error saving game", snapshot.ID, saveErr)
+		}
+		return err
+	}
+	return gm.repo.Delete(snapshot.ID)
+}
+
+// retireGame schedules an ended game for eviction. The live game is kept for a
+// while so connected players still see the final position.
+func (gm *GameManager) retireGame(gameID string) {
+	gm.archiveMu.Lock()
+	defer gm.archiveMu.Unlock()
+	if gm.archived[gameID] {
+		return
+	}
+	gm.archived[gameID] = true
+	time.AfterFunc(finishedGameRetention, func() {
+		gm.evictGame(gameID)
+	})
+}
+
+// evictGame drops an ended game from memory. Its row was deleted when it was
+// archived or aborted.
+func (gm *GameManager) evictGame(gameID string) {
+	gm.mu.Lock()
+	delete(gm.games, gameID)
+	gm.mu.Unlock()
+	gm.stopWriter(gameID)
+
+	gm.archiveMu.Lock()
+	delete(gm.archived, gameID)
+	gm.archiveMu.Unlock()
+}
+
+func (gm *GameManager) GetArchivedGame(gameID string) (model.ArchivedGame, error) {
+	return gm.archive.LoadArchive(gameID)
+}
+
+// GetPGN exports a live game if it's still loaded, otherwise its archive
+func (gm *GameManager) GetPGN(gameID string, playerID string) (chess.PGNGame, error) {
+	if game, err := gm.GetGame(gameID); err == nil {
+		return game.PGN(playerID)
+	}
+	archived, err := gm.archive.LoadArchive(gameID)
+	if err != nil {
+		return chess.PGNGame{}, err
+	}
+	return archived.PGN()
+}
+
+func (gm *GameManager) ListPlayerArchives(playerID string, filter repository.ArchiveFilter) ([]model.ArchivedGame, int, error) {
+	return gm.archive.ListArchivesByPlayer(playerID, filter)
+}
+
+// restoreGames loads every unfinished game from the repository, so a restart
+// doesn't lose games in progress. Games that finished but weren't archived
+// before the server stopped are archived now.
+func (gm *GameManager) restoreGames() error {
+	finished, err := gm.repo.ListFinished()
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range finished {
+		if snapshot.State.Status == model.GameStatusAborted {
+			err = gm.repo.Delete(snapshot.ID)
+		} else {
+			err = gm.storeArchive(snapshot)
+		}
+		if err != nil {
+			fmt.Println("Error archiving game", snapshot.ID, err)
+		}
+	}
+
+	snapshots, err := gm.repo.ListActive()
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range snapshots {
+		game := model.RestoreGame(snapshot)
+		game.OnCommit(gm.startWriter(snapshot.ID).push)
+		gm.games[snapshot.ID] = game
+	}
+	fmt.Println("Restored", len(snapshots), "games")
 	return nil
 }
 
@@ -188,12 +363,12 @@ func (gm *GameManager) AddPlayerToGame(gameID string, playerID string) (model.Pl
 	return game.AddPlayer(playerID)
 }
 
-func (gm *GameManager) JoinMatchmaking(playerID string) error {
+func (gm *GameManager) JoinMatchmaking(playerID string, timeControl model.TimeControl) error {
 	gm.mu.Lock()
 	defer gm.mu.Unlock()
 	fmt.Println("Joining matchmaking for player in game manager:", playerID)
 
-	err := gm.queue.AddPlayer(model.Player{ID: playerID})
+	err := gm.queue.AddPlayer(model.Player{ID: playerID}, timeControl)
 	if err != nil {
 		fmt.Println("Error adding player to matchmaking queue:", err)
 		return err
@@ -202,7 +377,7 @@ func (gm *GameManager) JoinMatchmaking(playerID string) error {
 	return nil
 }
 
-func (gm *GameManager) GetGameState(gameID string) (model.GameState, error) {
+func (gm *GameManager) GetGameState(gameID string, playerID string) (model.GameState, error) {
 	gm.mu.RLock()
 	defer gm.mu.RUnlock()
 	game, exists := gm.games[gameID]
@@ -210,10 +385,10 @@ func (gm *GameManager) GetGameState(gameID string) (model.GameState, error) {
 		return model.GameState{}, errors.New("game not found")
 	}
 
-	return game.GetState(), nil
+	return game.GetState(playerID)
 }
 
-func (gm *GameManager) MakeMove(gameID string, playerID string, move model.WSMove) error {
+func (gm *GameManager) MakeMove(gameID string, playerID string, requestID string, move model.WSMove) error {
 	gm.mu.Lock()
 	defer gm.mu.Unlock()
 
@@ -222,10 +397,10 @@ func (gm *GameManager) MakeMove(gameID string, playerID string, move model.WSMov
 		return errors.New("game not found")
 	}
 
-	return game.MakeMove(move)
+	return game.MakeMoveRequest(playerID, requestID, move)
 }
 
-func (gm *GameManager) RegisterConnection(gameID string, playerID string, conn *websocket.Conn) error {
+func (gm *GameManager) RegisterConnection(gameID string, playerID string, conn *ws.Connection) error {
 	fmt.Println("Registering connection in game manager")
 	gm.mu.Lock()
 	defer gm.mu.Unlock()
@@ -238,7 +413,26 @@ func (gm *GameManager) RegisterConnection(gameID string, playerID string, conn *
 	return game.RegisterConnection(playerID, conn)
 }
 
-func (gm *GameManager) UnregisterConnection(gameID string, playerID string) {
+func (gm *GameManager) RegisterSpectator(gameID string, playerID string, conn *ws.Connection) error {
+	game, err := gm.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	game.RegisterSpectator(playerID, conn)
+	return nil
+}
+
+func (gm *GameManager) UnregisterSpectator(gameID string, conn *ws.Connection) {
+	game, err := gm.GetGame(gameID)
+	if err != nil {
+		return
+	}
+
+	game.UnregisterSpectator(conn)
+}
+
+func (gm *GameManager) UnregisterConnection(gameID string, playerID string, conn *ws.Connection) {
 	fmt.Println("Unregistering connection in game manager")
 	gm.mu.Lock()
 	defer gm.mu.Unlock()
@@ -247,5 +441,174 @@ func (gm *GameManager) UnregisterConnection(gameID string, playerID string) {
 		return
 	}
 
-	game.UnregisterConnection(playerID)
+	game.UnregisterConnection(playerID, conn)
+}
+
+func (gm *GameManager) Premove(gameID string, playerID string, move model.WSMove) error {
+	game, err := gm.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	return game.Premove(playerID, move)
+}
+
+func (gm *GameManager) CancelPremove(gameID string, playerID string) error {
+	game, err := gm.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	return game.CancelPremove(playerID)
+}
+
+func (gm *GameManager) RequestTakeback(gameID string, playerID string) error {
+	game, err := gm.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	return game.RequestTakeback(playerID)
+}
+
+func (gm *GameManager) AcceptTakeback(gameID string, playerID string) error {
+	game, err := gm.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	return game.AcceptTakeback(playerID)
+}
+
+func (gm *GameManager) DeclineTakeback(gameID string, playerID string) error {
+	game, err := gm.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	return game.DeclineTakeback(playerID)
+}
+
+func (gm *GameManager) OfferRematch(gameID string, playerID string) error {
+	game, err := gm.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	rematch, err := game.OfferRematch(playerID, uuid.New().String())
+	if err != nil || rematch == nil {
+		return err
+	}
+	return gm.startRematch(game, rematch)
+}
+
+func (gm *GameManager) AcceptRematch(gameID string, playerID string) error {
+	game, err := gm.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	rematch, err := game.AcceptRematch(playerID, uuid.New().String())
+	if err != nil {
+		return err
+	}
+	return gm.startRematch(game, rematch)
+}
+
+// startRematch creates the rematch agreed in game, then sends both players to it
+func (gm *GameManager) startRematch(game *model.Game, rematch *model.Rematch) error {
+	next, err := model.NewRematchGame(rematch)
+	if err != nil {
+		game.CancelRematch()
+		return err
+	}
+
+	gm.mu.Lock()
+	gm.track(next)
+	gm.games[rematch.ID] = next
+	gm.mu.Unlock()
+
+	game.StartRematch(rematch)
+	return nil
+}
+
+func (gm *GameManager) Resync(gameID string, conn *ws.Connection) error {
+	game, err := gm.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	return game.Resync(conn)
+}
+
+func (gm *GameManager) Resign(gameID string, playerID string) error {
+	game, err := gm.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	return game.Resign(playerID)
+}
+
+func (gm *GameManager) OfferDraw(gameID string, playerID string) error {
+	game, err := gm.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	return game.OfferDraw(playerID)
+}
+
+func (gm *GameManager) AcceptDraw(gameID string, playerID string) error {
+	game, err := gm.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	return game.AcceptDraw(playerID)
+}
+
+func (gm *GameManager) DeclineDraw(gameID string, playerID string) error {
+	game, err := gm.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	return game.DeclineDraw(playerID)
+}
+
+func (gm *GameManager) WithdrawDrawOffer(gameID string, playerID string) error {
+	game, err := gm.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	return game.WithdrawDrawOffer(playerID)
+}
+
+func (gm *GameManager) ClaimDraw(gameID string, playerID string) error {
+	game, err := gm.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	return game.ClaimDraw(playerID)
+}
+
+func (gm *GameManager) Abort(gameID string, playerID string) error {
+	game, err := gm.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	return game.Abort(playerID)
+}
+
+func (gm *GameManager) ClaimDisconnect(gameID string, playerID string, claim model.DisconnectClaim) error {
+	game, err := gm.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	return game.ClaimDisconnect(playerID, claim)
 }
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbeisheim/minechess-backend/internal/model"
+	"github.com/benbeisheim/minechess-backend/internal/repository"
+)
+
+func finishedSnapshot(t *testing.T, gameID string) model.GameSnapshot {
+	t.Helper()
+	game := model.NewGame(gameID, model.TimeControl{Base: 300}, model.Rules{})
+	for _, playerID := range []string{"white", "black"} {
+		if _, err := game.AddPlayer(playerID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := game.Resign("black"); err != nil {
+		t.Fatal(err)
+	}
+	return game.Snapshot()
+}
+
+// A game that finished without being archived, say because the server stopped
+// first, is archived on the next start and its live row deleted
+func TestRestoreArchivesFinishedGames(t *testing.T) {
+	repo := repository.NewMemoryGameRepository()
+	if err := repo.Save(finishedSnapshot(t, "finished")); err != nil {
+		t.Fatal(err)
+	}
+
+	NewGameManager(repo, repo)
+
+	// restoring happens before the manager is returned
+	if _, err := repo.LoadArchive("finished"); err != nil {
+		t.Errorf("finished game wasn't archived: %v", err)
+	}
+	if _, err := repo.Load("finished"); err != repository.ErrGameNotFound {
+		t.Errorf("finished game's live row wasn't deleted: %v", err)
+	}
+}
+
+func TestFinishingGameDeletesLiveRow(t *testing.T) {
+	repo := repository.NewMemoryGameRepository()
+	gm := NewGameManager(repo, repo)
+	if err := gm.CreateGame("game", model.TimeControl{Base: 300}, "", model.Rules{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, playerID := range []string{"white", "black"} {
+		if _, err := gm.AddPlayerToGame("game", playerID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	eventually(t, "game in progress is saved", func() bool {
+		_, err := repo.Load("game")
+		return err == nil
+	})
+
+	game, err := gm.GetGame("game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := game.Resign("black"); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "finished game is archived and its live row deleted", func() bool {
+		_, archiveErr := repo.LoadArchive("game")
+		_, err := repo.Load("game")
+		return archiveErr == nil && err == repository.ErrGameNotFound
+	})
+}
+
+// eventually waits for what a game's writer saves in the background
+func eventually(t *testing.T, what string, ok func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !ok() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// stalledRepo holds every save until it's released, like a slow disk
+type stalledRepo struct {
+	*repository.MemoryGameRepository
+	release chan struct{}
+}
+
+func (r stalledRepo) Save(snapshot model.GameSnapshot) error {
+	<-r.release
+	return r.MemoryGameRepository.Save(snapshot)
+}
+
+func TestSlowSaveDoesNotHoldUpGames(t *testing.T) {
+	repo := stalledRepo{repository.NewMemoryGameRepository(), make(chan struct{})}
+	gm := NewGameManager(repo, repo)
+	if err := gm.CreateGame("game", model.TimeControl{Base: 300}, "", model.Rules{}); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		for _, playerID := range []string{"white", "black"} {
+			if _, err := gm.AddPlayerToGame("game", playerID); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- gm.MakeMove("game", "white", "", model.WSMove{
+			From: model.Position{X: 4, Y: 6},
+			To:   model.Position{X: 4, Y: 4},
+			Mine: &model.Position{X: 0, Y: 2},
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the game waited for the repository")
+	}
+
+	// once the disk catches up, the latest state is what's stored
+	close(repo.release)
+	eventually(t, "the move is saved", func() bool {
+		snapshot, err := repo.Load("game")
+		return err == nil && len(snapshot.State.MoveHistory) == 1
+	})
+}
+
+func TestAgreedRematchIsCreated(t *testing.T) {
+	repo := repository.NewMemoryGameRepository()
+	gm := NewGameManager(repo, repo)
+	if err := gm.CreateGame("game", model.TimeControl{Base: 300}, "", model.Rules{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, playerID := range []string{"white", "black"} {
+		if _, err := gm.AddPlayerToGame("game", playerID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gm.Resign("game", "black"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gm.OfferRematch("game", "black"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gm.AcceptRematch("game", "white"); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := gm.GetGameState("game", "white")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Rematch == nil {
+		t.Fatal("the finished game doesn't point to its rematch")
+	}
+	rematch, err := gm.GetGameState(*state.Rematch, "white")
+	if err != nil {
+		t.Fatalf("the rematch wasn't created: %v", err)
+	}
+	if rematch.Players.White.ID != "black" || rematch.Players.Black.ID != "white" {
+		t.Errorf("rematch has %s as white, want black", rematch.Players.White.ID)
+	}
+}
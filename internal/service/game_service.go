@@ -4,7 +4,8 @@ import (
 	"fmt"
 
 	"github.com/benbeisheim/minechess-backend/internal/model"
-	"github.com/gofiber/websocket/v2"
+	"github.com/benbeisheim/minechess-backend/internal/repository"
+	"github.com/benbeisheim/minechess-backend/internal/ws"
 	"github.com/google/uuid"
 )
 
@@ -22,41 +23,149 @@ func (gs *GameService) JoinGame(gameID string, playerID string) (model.PlayerCol
 	return gs.gameManager.AddPlayerToGame(gameID, playerID)
 }
 
-func (gs *GameService) CreateGame() (string, error) {
+func (gs *GameService) CreateGame(timeControl model.TimeControl, fen string, rules model.Rules) (string, error) {
+	if err := timeControl.Validate(); err != nil {
+		return "", fmt.Errorf("invalid time control: %w", err)
+	}
 	gameID := uuid.New().String()
 
-	if err := gs.gameManager.CreateGame(gameID); err != nil {
+	if err := gs.gameManager.CreateGame(gameID, timeControl, fen, rules); err != nil {
 		return "", fmt.Errorf("failed to create game: %w", err)
 	}
 
 	return gameID, nil
 }
 
-func (gs *GameService) JoinMatchmaking(playerID string) error {
+func (gs *GameService) JoinMatchmaking(playerID string, timeControl model.TimeControl) error {
 	fmt.Println("Joining matchmaking for player in game service:", playerID)
-	return gs.gameManager.JoinMatchmaking(playerID)
+	if err := timeControl.Validate(); err != nil {
+		return fmt.Errorf("invalid time control: %w", err)
+	}
+	return gs.gameManager.JoinMatchmaking(playerID, timeControl)
+}
+
+func (gs *GameService) GetGameState(gameID string, playerID string) (model.GameState, error) {
+	return gs.gameManager.GetGameState(gameID, playerID)
+}
+
+func (gs *GameService) GetArchivedGame(gameID string) (model.ArchivedGame, error) {
+	return gs.gameManager.GetArchivedGame(gameID)
 }
 
-func (gs *GameService) GetGameState(gameID string) (model.GameState, error) {
-	return gs.gameManager.GetGameState(gameID)
+func (gs *GameService) GetPGN(gameID string, playerID string) (string, error) {
+	pgn, err := gs.gameManager.GetPGN(gameID, playerID)
+	if err != nil {
+		return "", err
+	}
+	return pgn.String(), nil
+}
+
+// ListPlayerGames returns one page of a player's finished games and the total
+// number of games matching the result filter
+func (gs *GameService) ListPlayerGames(playerID string, result model.PlayerResult, page int, pageSize int) ([]model.ArchivedGame, int, error) {
+	switch result {
+	case "", model.PlayerResultWin, model.PlayerResultLoss, model.PlayerResultDraw:
+	default:
+		return nil, 0, fmt.Errorf("unknown result filter: %s", result)
+	}
+	return gs.gameManager.ListPlayerArchives(playerID, repository.ArchiveFilter{
+		Result: result,
+		Offset: (page - 1) * pageSize,
+		Limit:  pageSize,
+	})
 }
 
-func (gs *GameService) HandleMove(gameID string, playerID string, move model.WSMove) error {
-	if err := gs.gameManager.MakeMove(gameID, playerID, move); err != nil {
+// HandleMove makes a move. A move sent again with the same non-empty requestID
+// is only made once.
+func (gs *GameService) HandleMove(gameID string, playerID string, requestID string, move model.WSMove) error {
+	if err := gs.gameManager.MakeMove(gameID, playerID, requestID, move); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (gs *GameService) RegisterConnection(gameID string, playerID string, conn *websocket.Conn) error {
+func (gs *GameService) HandleResign(gameID string, playerID string) error {
+	return gs.gameManager.Resign(gameID, playerID)
+}
+
+func (gs *GameService) HandleDrawOffer(gameID string, playerID string) error {
+	return gs.gameManager.OfferDraw(gameID, playerID)
+}
+
+func (gs *GameService) HandleDrawAccept(gameID string, playerID string) error {
+	return gs.gameManager.AcceptDraw(gameID, playerID)
+}
+
+func (gs *GameService) HandleDrawDecline(gameID string, playerID string) error {
+	return gs.gameManager.DeclineDraw(gameID, playerID)
+}
+
+func (gs *GameService) HandleDrawWithdraw(gameID string, playerID string) error {
+	return gs.gameManager.WithdrawDrawOffer(gameID, playerID)
+}
+
+func (gs *GameService) HandleDrawClaim(gameID string, playerID string) error {
+	return gs.gameManager.ClaimDraw(gameID, playerID)
+}
+
+func (gs *GameService) HandleAbort(gameID string, playerID string) error {
+	return gs.gameManager.Abort(gameID, playerID)
+}
+
+func (gs *GameService) HandleDisconnectClaim(gameID string, playerID string, claim model.DisconnectClaim) error {
+	return gs.gameManager.ClaimDisconnect(gameID, playerID, claim)
+}
+
+func (gs *GameService) HandlePremove(gameID string, playerID string, move model.WSMove) error {
+	return gs.gameManager.Premove(gameID, playerID, move)
+}
+
+func (gs *GameService) HandleCancelPremove(gameID string, playerID string) error {
+	return gs.gameManager.CancelPremove(gameID, playerID)
+}
+
+func (gs *GameService) HandleTakebackRequest(gameID string, playerID string) error {
+	return gs.gameManager.RequestTakeback(gameID, playerID)
+}
+
+func (gs *GameService) HandleTakebackAccept(gameID string, playerID string) error {
+	return gs.gameManager.AcceptTakeback(gameID, playerID)
+}
+
+func (gs *GameService) HandleTakebackDecline(gameID string, playerID string) error {
+	return gs.gameManager.DeclineTakeback(gameID, playerID)
+}
+
+func (gs *GameService) HandleRematchOffer(gameID string, playerID string) error {
+	return gs.gameManager.OfferRematch(gameID, playerID)
+}
+
+func (gs *GameService) HandleRematchAccept(gameID string, playerID string) error {
+	return gs.gameManager.AcceptRematch(gameID, playerID)
+}
+
+// HandleResync resends the full state to a client that missed a delta
+func (gs *GameService) HandleResync(gameID string, conn *ws.Connection) error {
+	return gs.gameManager.Resync(gameID, conn)
+}
+
+func (gs *GameService) RegisterConnection(gameID string, playerID string, conn *ws.Connection) error {
 	fmt.Println("Registering connection in game service")
 	return gs.gameManager.RegisterConnection(gameID, playerID, conn)
 }
 
-func (gs *GameService) UnregisterConnection(gameID string, playerID string) {
+func (gs *GameService) RegisterSpectator(gameID string, playerID string, conn *ws.Connection) error {
+	return gs.gameManager.RegisterSpectator(gameID, playerID, conn)
+}
+
+func (gs *GameService) UnregisterSpectator(gameID string, conn *ws.Connection) {
+	gs.gameManager.UnregisterSpectator(gameID, conn)
+}
+
+func (gs *GameService) UnregisterConnection(gameID string, playerID string, conn *ws.Connection) {
 	fmt.Println("Unregistering connection in game service")
-	gs.gameManager.UnregisterConnection(gameID, playerID)
+	gs.gameManager.UnregisterConnection(gameID, playerID, conn)
 }
 
 func (gs *GameService) RegisterMatchmakingChannel(playerID string, ch chan string) error {
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/benbeisheim/minechess-backend/internal/model"
+)
+
+// gameWriter persists one game's snapshots on its own goroutine, so a slow
+// write never holds up the game, which commits with its lock held, or the
+// game manager. Only the latest snapshot needs storing, so one that arrives
+// while another is still waiting replaces it.
+type gameWriter struct {
+	save    func(model.GameSnapshot)
+	mu      sync.Mutex
+	pending *model.GameSnapshot
+	wake    chan struct{}
+	stop    chan struct{}
+	stopped sync.Once
+}
+
+func newGameWriter(save func(model.GameSnapshot)) *gameWriter {
+	w := &gameWriter{
+		save: save,
+		wake: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// push queues snapshot to be saved. It never blocks, so it's safe to call from
+// a commit.
+func (w *gameWriter) push(snapshot model.GameSnapshot) {
+	w.mu.Lock()
+	w.pending = &snapshot
+	w.mu.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// close stops the writer once whatever is pending has been saved
+func (w *gameWriter) close() {
+	w.stopped.Do(func() { close(w.stop) })
+}
+
+func (w *gameWriter) run() {
+	for {
+		select {
+		case <-w.wake:
+			w.flush()
+		case <-w.stop:
+			w.flush()
+			return
+		}
+	}
+}
+
+func (w *gameWriter) flush() {
+	w.mu.Lock()
+	snapshot := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+
+	if snapshot != nil {
+		w.save(*snapshot)
+	}
+}
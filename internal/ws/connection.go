@@ -0,0 +1,128 @@
+package ws
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+const (
+	// outboundQueueSize is how many messages may wait for a slow client before
+	// it's disconnected
+	outboundQueueSize = 64
+	writeTimeout      = 10 * time.Second
+)
+
+// Socket is the writing side of a websocket, as used by Connection. It's
+// satisfied by *websocket.Conn.
+type Socket interface {
+	SetWriteDeadline(t time.Time) error
+	WriteJSON(v interface{}) error
+	WriteMessage(messageType int, data []byte) error
+	Close() error
+}
+
+// Connection owns the writing side of a socket. The socket doesn't allow
+// concurrent writes, so everything sent to the client is queued and written
+// in order by a single goroutine.
+type Connection struct {
+	conn      Socket
+	queue     chan Message
+	done      chan struct{}
+	closeOnce sync.Once
+	closeCode int
+	reason    string
+	dropped   bool // set before done is closed; the queue is thrown away
+}
+
+// NewConnection wraps conn and starts its writer. Close must be called once
+// the socket is no longer read, to stop the writer.
+func NewConnection(conn Socket) *Connection {
+	c := &Connection{
+		conn:  conn,
+		queue: make(chan Message, outboundQueueSize),
+		done:  make(chan struct{}),
+	}
+	go c.writeLoop()
+	return c
+}
+
+// Send queues msg for the client. A client that has let the queue fill up is
+// too slow to keep up and is disconnected instead. Send never blocks, so it's
+// safe to call with locks held.
+func (c *Connection) Send(msg Message) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.queue <- msg:
+		return true
+	default:
+		fmt.Printf("Outbound queue full for connection %p, disconnecting\n", c.conn)
+		c.drop()
+		return false
+	}
+}
+
+// drop disconnects a client too slow to keep up. What's queued for it is
+// thrown away and the socket closed at once, which also fails a write in
+// progress, so a stalled client can't hold on to the writer.
+func (c *Connection) drop() {
+	c.closeOnce.Do(func() {
+		c.dropped = true
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// Close sends a close frame with the given code and reason after any
+// messages already queued, then closes the socket
+func (c *Connection) Close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.reason = reason
+		close(c.done)
+	})
+}
+
+func (c *Connection) writeLoop() {
+	defer c.conn.Close()
+	for {
+		select {
+		case msg := <-c.queue:
+			if !c.write(msg) {
+				return
+			}
+		case <-c.done:
+			if c.dropped {
+				return
+			}
+			for {
+				select {
+				case msg := <-c.queue:
+					if !c.write(msg) {
+						return
+					}
+				default:
+					c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+					c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, c.reason))
+					return
+				}
+			}
+		}
+	}
+}
+
+func (c *Connection) write(msg Message) bool {
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := c.conn.WriteJSON(msg); err != nil {
+		fmt.Printf("Failed to write %s to connection %p: %v\n", msg.Type, c.conn, err)
+		c.Close(websocket.CloseGoingAway, "")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+package ws
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+// fakeSocket records what's written to it. A stalled socket blocks every write
+// until it's closed, like a client that has stopped reading.
+type fakeSocket struct {
+	stalled bool
+	mu      sync.Mutex
+	written []Message
+	stalls  int // writes attempted while stalled
+	frames  []int
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func newFakeSocket(stalled bool) *fakeSocket {
+	return &fakeSocket{stalled: stalled, closed: make(chan struct{})}
+}
+
+func (s *fakeSocket) SetWriteDeadline(time.Time) error { return nil }
+
+func (s *fakeSocket) WriteJSON(v interface{}) error {
+	if s.stalled {
+		s.mu.Lock()
+		s.stalls++
+		s.mu.Unlock()
+		<-s.closed
+		return errors.New("closed")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.written = append(s.written, v.(Message))
+	return nil
+}
+
+func (s *fakeSocket) WriteMessage(messageType int, data []byte) error {
+	if s.stalled {
+		s.mu.Lock()
+		s.stalls++
+		s.mu.Unlock()
+		<-s.closed
+		return errors.New("closed")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.frames = append(s.frames, messageType)
+	return nil
+}
+
+func (s *fakeSocket) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return nil
+}
+
+func waitClosed(t *testing.T, s *fakeSocket) {
+	t.Helper()
+	select {
+	case <-s.closed:
+	case <-time.After(time.Second):
+		t.Fatal("socket wasn't closed")
+	}
+}
+
+func TestCloseWritesQueuedMessagesFirst(t *testing.T) {
+	socket := newFakeSocket(false)
+	c := NewConnection(socket)
+	for i := 0; i < 3; i++ {
+		c.Send(Message{Type: MessageTypeGameState, Seq: uint64(i + 1)})
+	}
+	c.Close(websocket.CloseNormalClosure, "")
+	waitClosed(t, socket)
+
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+	if len(socket.written) != 3 {
+		t.Errorf("wrote %d messages, want 3", len(socket.written))
+	}
+	if len(socket.frames) != 1 || socket.frames[0] != websocket.CloseMessage {
+		t.Errorf("frames %v, want a close frame", socket.frames)
+	}
+	if c.Send(Message{Type: MessageTypeGameState}) {
+		t.Error("a closed connection accepted a message")
+	}
+}
+
+func TestSlowConsumerIsDroppedAtOnce(t *testing.T) {
+	socket := newFakeSocket(true)
+	c := NewConnection(socket)
+	sent := 0
+	for i := 0; i < outboundQueueSize+2; i++ {
+		if c.Send(Message{Type: MessageTypeGameState}) {
+			sent++
+		}
+	}
+	// the writer is stuck on the first message, so the queue overflows
+	waitClosed(t, socket)
+	if sent > outboundQueueSize+1 {
+		t.Errorf("%d messages accepted, want the rest dropped", sent)
+	}
+	if c.Send(Message{Type: MessageTypeGameState}) {
+		t.Error("a dropped connection accepted a message")
+	}
+
+	// nothing more is written once the stuck write fails
+	time.Sleep(50 * time.Millisecond)
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+	if socket.stalls > 1 {
+		t.Errorf("%d writes attempted, want at most the one in progress", socket.stalls)
+	}
+}
+
+func TestMessagesAreWrittenInOrder(t *testing.T) {
+	socket := newFakeSocket(false)
+	c := NewConnection(socket)
+	for i := 0; i < outboundQueueSize; i++ {
+		c.Send(Message{Type: MessageTypeGameState, Seq: uint64(i + 1)})
+	}
+	c.Close(websocket.CloseNormalClosure, "")
+	waitClosed(t, socket)
+
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+	for i, msg := range socket.written {
+		if msg.Seq != uint64(i+1) {
+			t.Fatalf("message %d has seq %d, want %d", i, msg.Seq, i+1)
+		}
+	}
+}
@@ -8,16 +8,39 @@ import (
 type MessageType string
 
 const (
-	MessageTypeMove      MessageType = "move"
-	MessageTypeGameState MessageType = "gameState"
-	MessageTypeDrawOffer MessageType = "drawOffer"
-	MessageTypeResign    MessageType = "resign"
-	MessageTypeDraw      MessageType = "draw"
-	MessageTypeError     MessageType = "error"
+	MessageTypeMove                 MessageType = "move"
+	MessageTypeMoveAccepted         MessageType = "moveAccepted"
+	MessageTypeMoveRejected         MessageType = "moveRejected"
+	MessageTypePremove              MessageType = "premove"
+	MessageTypeCancelPremove        MessageType = "cancelPremove"
+	MessageTypePremoveStatus        MessageType = "premoveStatus"
+	MessageTypeGameState            MessageType = "gameState"
+	MessageTypeStateDelta           MessageType = "stateDelta"
+	MessageTypeResync               MessageType = "resync"
+	MessageTypeDrawOffer            MessageType = "drawOffer"
+	MessageTypeDrawAccept           MessageType = "drawAccept"
+	MessageTypeDrawDecline          MessageType = "drawDecline"
+	MessageTypeDrawWithdraw         MessageType = "drawWithdraw"
+	MessageTypeDrawClaim            MessageType = "drawClaim"
+	MessageTypeAbort                MessageType = "abort"
+	MessageTypeDisconnectClaim      MessageType = "disconnectClaim"
+	MessageTypeOpponentDisconnected MessageType = "opponentDisconnected"
+	MessageTypeOpponentReconnected  MessageType = "opponentReconnected"
+	MessageTypeTakebackRequest      MessageType = "takebackRequest"
+	MessageTypeTakebackAccept       MessageType = "takebackAccept"
+	MessageTypeTakebackDecline      MessageType = "takebackDecline"
+	MessageTypeRematchOffer         MessageType = "rematchOffer"
+	MessageTypeRematchAccept        MessageType = "rematchAccept"
+	MessageTypeRematch              MessageType = "rematch" // the agreed rematch has been created
+	MessageTypeResign               MessageType = "resign"
+	MessageTypeDraw                 MessageType = "draw" // alias of drawAccept
+	MessageTypeError                MessageType = "error"
 )
 
 // Message represents a WebSocket message in our system
 type Message struct {
-	Type    MessageType     `json:"type"`
-	Payload json.RawMessage `json:"payload"`
+	Type      MessageType     `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	Seq       uint64          `json:"seq,omitempty"`       // orders gameState and stateDelta messages; a client ignores any older than the last it applied
+	RequestID string          `json:"requestId,omitempty"` // set by the client on a command, and echoed on the reply to it
 }
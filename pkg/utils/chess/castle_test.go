@@ -0,0 +1,100 @@
+package chess
+
+import "testing"
+
+var (
+	e1 = Square{X: 4, Y: 7}
+	g1 = Square{X: 6, Y: 7}
+	c1 = Square{X: 2, Y: 7}
+	f1 = Square{X: 5, Y: 7}
+	h1 = Square{X: 7, Y: 7}
+)
+
+func TestCastling(t *testing.T) {
+	tests := []struct {
+		name  string
+		fen   string
+		setup func(*Position)
+		to    Square
+		legal bool
+	}{
+		{name: "kingside", fen: "4k3/8/8/8/8/8/8/4K2R w K - 0 1", to: g1, legal: true},
+		{name: "queenside with b1 attacked", fen: "4k3/1r6/8/8/8/8/8/R3K3 w Q - 0 1", to: c1, legal: true},
+		{name: "out of check", fen: "4k3/4r3/8/8/8/8/8/4K2R w K - 0 1", to: g1},
+		{name: "through an attacked square", fen: "4k3/5r2/8/8/8/8/8/4K2R w K - 0 1", to: g1},
+		{name: "into check", fen: "4k3/6r1/8/8/8/8/8/4K2R w K - 0 1", to: g1},
+		{name: "through a piece", fen: "4k3/8/8/8/8/8/8/4KB1R w K - 0 1", to: g1},
+		{name: "without the right", fen: "4k3/8/8/8/8/8/8/4K2R w - - 0 1", to: g1},
+		{
+			name: "enemy rook on the rook's square",
+			fen:  "4k3/8/8/8/8/8/8/4K2R w K - 0 1",
+			setup: func(pos *Position) {
+				pos.Board.set(h1, Piece{Type: Rook, Color: Black})
+			},
+			to: g1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, _, err := ParseFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.setup != nil {
+				tt.setup(&pos)
+			}
+			if got := pos.IsLegal(Move{From: e1, To: tt.to}); got != tt.legal {
+				t.Errorf("IsLegal(e1-%s) = %v, want %v", tt.to, got, tt.legal)
+			}
+		})
+	}
+}
+
+func TestCastlingRookBlast(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		blast   bool
+	}{
+		{"off", Options{}, false},
+		{"on", Options{RookDetonatesMine: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, _, err := ParseFEN("4k3/8/8/8/8/8/8/4K2R w K - 0 1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			pos.Options = tt.options
+			mine := f1
+			next, events, err := pos.Apply(Move{From: e1, To: g1}, &mine)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var exploded *Event
+			for i := range events {
+				if events[i].Type == EventExplosion {
+					exploded = &events[i]
+				}
+			}
+			if (exploded != nil) != tt.blast {
+				t.Fatalf("explosion = %v, want %v", exploded, tt.blast)
+			}
+			if next.Board.At(g1).Type != King {
+				t.Errorf("king didn't land on g1")
+			}
+			rook := next.Board.At(f1)
+			if tt.blast {
+				if exploded.Square != f1 || exploded.Piece.Type != Rook {
+					t.Errorf("explosion %+v, want the rook on f1", *exploded)
+				}
+				if !rook.IsEmpty() {
+					t.Errorf("the rook survived the blast")
+				}
+			} else if rook.Type != Rook {
+				t.Errorf("f1 = %+v, want the rook", rook)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package chess
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Minechess FEN is standard FEN with an optional seventh field holding the
+// armed mine square, or "-" when there is none:
+//
+//	rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1 c6
+//
+// Castling rights are derived from whether kings and rooks have moved.
+
+const StartingFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+var fenLetters = map[PieceType]byte{King: 'k', Queen: 'q', Rook: 'r', Bishop: 'b', Knight: 'n', Pawn: 'p'}
+
+// castlingRook gives the rook home square for each castling right
+var castlingRook = map[byte]Square{
+	'K': {X: 7, Y: 7},
+	'Q': {X: 0, Y: 7},
+	'k': {X: 7, Y: 0},
+	'q': {X: 0, Y: 0},
+}
+
+func castlingColor(right byte) Color {
+	if right == 'K' || right == 'Q' {
+		return White
+	}
+	return Black
+}
+
+func homeRank(c Color) int {
+	if c == White {
+		return 7
+	}
+	return 0
+}
+
+// CastlingRights returns the FEN castling field for the position
+func (p Position) CastlingRights() string {
+	rights := ""
+	for _, right := range []byte("KQkq") {
+		color := castlingColor(right)
+		king := p.Board[homeRank(color)][4]
+		rook := p.Board.At(castlingRook[right])
+		if king.Type == King && king.Color == color && !king.HasMoved && rook.Type == Rook && rook.Color == color && !rook.HasMoved {
+			rights += string(right)
+		}
+	}
+	if rights == "" {
+		return "-"
+	}
+	return rights
+}
+
+// FEN serializes the position, adding the mine as a seventh field when given
+func (p Position) FEN(mine *Square) string {
+	var placement strings.Builder
+	for y := 0; y < 8; y++ {
+		empty := 0
+		for x := 0; x < 8; x++ {
+			piece := p.Board[y][x]
+			if piece.IsEmpty() {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				placement.WriteString(strconv.Itoa(empty))
+				empty = 0
+			}
+			letter := fenLetters[piece.Type]
+			if piece.Color == White {
+				letter -= 'a' - 'A'
+			}
+			placement.WriteByte(letter)
+		}
+		if empty > 0 {
+			placement.WriteString(strconv.Itoa(empty))
+		}
+		if y < 7 {
+			placement.WriteByte('/')
+		}
+	}
+
+	enPassant := "-"
+	if p.EnPassant != nil {
+		enPassant = p.EnPassant.String()
+	}
+	fields := []string{
+		placement.String(),
+		string(p.ToMove[0]),
+		p.CastlingRights(),
+		enPassant,
+		strconv.Itoa(p.HalfmoveClock),
+		strconv.Itoa(max(p.FullmoveNumber, 1)),
+	}
+	if mine != nil {
+		fields = append(fields, mine.String())
+	}
+	return strings.Join(fields, " ")
+}
+
+// ParseFEN reads a standard or minechess FEN. The mine is nil unless the
+// seventh field names a square. The counters may be left off.
+func ParseFEN(fen string) (Position, *Square, error) {
+	fields := strings.Fields(fen)
+	if len(fields) < 4 || len(fields) > 7 {
+		return Position{}, nil, fmt.Errorf("invalid FEN: expected 4 to 7 fields, got %d", len(fields))
+	}
+	pos := Position{FullmoveNumber: 1}
+
+	rows := strings.Split(fields[0], "/")
+	if len(rows) != 8 {
+		return pos, nil, errors.New("invalid FEN: board must have 8 ranks")
+	}
+	for y, row := range rows {
+		x := 0
+		for i := 0; i < len(row); i++ {
+			c := row[i]
+			if c >= '1' && c <= '8' {
+				x += int(c - '0')
+				continue
+			}
+			pieceType, color, ok := pieceFromLetter(c)
+			if !ok {
+				return pos, nil, fmt.Errorf("invalid FEN: unknown piece %q", c)
+			}
+			if x > 7 {
+				return pos, nil, fmt.Errorf("invalid FEN: rank %d is too long", 8-y)
+			}
+			pos.Board[y][x] = Piece{Type: pieceType, Color: color, HasMoved: true}
+			x++
+		}
+		if x != 8 {
+			return pos, nil, fmt.Errorf("invalid FEN: rank %d has %d squares", 8-y, x)
+		}
+	}
+
+	switch fields[1] {
+	case "w":
+		pos.ToMove = White
+	case "b":
+		pos.ToMove = Black
+	default:
+		return pos, nil, fmt.Errorf("invalid FEN: unknown side to move %q", fields[1])
+	}
+
+	// pawns on their starting rank haven't moved; kings and rooks haven't moved
+	// if they still have a castling right
+	for x := 0; x < 8; x++ {
+		if pawn := &pos.Board[6][x]; pawn.Type == Pawn && pawn.Color == White {
+			pawn.HasMoved = false
+		}
+		if pawn := &pos.Board[1][x]; pawn.Type == Pawn && pawn.Color == Black {
+			pawn.HasMoved = false
+		}
+	}
+	if fields[2] != "-" {
+		for i := 0; i < len(fields[2]); i++ {
+			right := fields[2][i]
+			rookSquare, ok := castlingRook[right]
+			if !ok {
+				return pos, nil, fmt.Errorf("invalid FEN: unknown castling right %q", right)
+			}
+			color := castlingColor(right)
+			king := &pos.Board[homeRank(color)][4]
+			rook := &pos.Board[rookSquare.Y][rookSquare.X]
+			if king.Type != King || king.Color != color || rook.Type != Rook || rook.Color != color {
+				return pos, nil, fmt.Errorf("invalid FEN: castling right %q without king and rook in place", right)
+			}
+			king.HasMoved = false
+			rook.HasMoved = false
+		}
+	}
+
+	if fields[3] != "-" {
+		enPassant, err := ParseSquare(fields[3])
+		if err != nil {
+			return pos, nil, fmt.Errorf("invalid FEN: %w", err)
+		}
+		pos.EnPassant = &enPassant
+	}
+
+	if len(fields) > 4 {
+		halfmove, err := strconv.Atoi(fields[4])
+		if err != nil || halfmove < 0 {
+			return pos, nil, fmt.Errorf("invalid FEN: bad halfmove clock %q", fields[4])
+		}
+		pos.HalfmoveClock = halfmove
+	}
+	if len(fields) > 5 {
+		fullmove, err := strconv.Atoi(fields[5])
+		if err != nil || fullmove < 1 {
+			return pos, nil, fmt.Errorf("invalid FEN: bad fullmove number %q", fields[5])
+		}
+		pos.FullmoveNumber = fullmove
+	}
+
+	var mine *Square
+	if len(fields) > 6 && fields[6] != "-" {
+		square, err := ParseSquare(fields[6])
+		if err != nil {
+			return pos, nil, fmt.Errorf("invalid FEN: bad mine square: %w", err)
+		}
+		mine = &square
+	}
+
+	if err := pos.Validate(); err != nil {
+		return pos, nil, err
+	}
+	return pos, mine, nil
+}
+
+func pieceFromLetter(c byte) (PieceType, Color, bool) {
+	color := Black
+	if c >= 'A' && c <= 'Z' {
+		color = White
+		c += 'a' - 'A'
+	}
+	for pieceType, letter := range fenLetters {
+		if letter == c {
+			return pieceType, color, true
+		}
+	}
+	return "", "", false
+}
+
+// Validate checks that a position could be played from: one king each, no
+// pawns on the back ranks, and the side not to move not in check
+func (p Position) Validate() error {
+	kings := map[Color]int{}
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			piece := p.Board[y][x]
+			if piece.Type == King {
+				kings[piece.Color]++
+			}
+			if piece.Type == Pawn && (y == 0 || y == 7) {
+				return errors.New("invalid position: pawn on the back rank")
+			}
+		}
+	}
+	if kings[White] != 1 || kings[Black] != 1 {
+		return errors.New("invalid position: each side needs exactly one king")
+	}
+	if p.InCheck(p.ToMove.Opponent()) {
+		return errors.New("invalid position: the side not to move is in check")
+	}
+	if p.EnPassant != nil {
+		rank := 2
+		if p.ToMove == Black {
+			rank = 5
+		}
+		if p.EnPassant.Y != rank {
+			return fmt.Errorf("invalid position: en passant square %s is on the wrong rank", p.EnPassant)
+		}
+	}
+	return nil
+}
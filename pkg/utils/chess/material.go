@@ -0,0 +1,44 @@
+package chess
+
+// material counts the non-king pieces of one colour by type.
+func (p Position) material(c Color) map[PieceType]int {
+	counts := map[PieceType]int{}
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if piece := p.Board[y][x]; !piece.IsEmpty() && piece.Color == c && piece.Type != King {
+				counts[piece.Type]++
+			}
+		}
+	}
+	return counts
+}
+
+func total(counts map[PieceType]int) int {
+	n := 0
+	for _, count := range counts {
+		n += count
+	}
+	return n
+}
+
+// InsufficientMaterial reports whether c has no way left to win: a bare king,
+// or a king and a single minor piece against a bare king. Mines don't change
+// this, since a bare king survives stepping on one.
+func InsufficientMaterial(pos Position, c Color) bool {
+	own := pos.material(c)
+	switch total(own) {
+	case 0:
+		return true
+	case 1:
+		return (own[Knight] == 1 || own[Bishop] == 1) && total(pos.material(c.Opponent())) == 0
+	}
+	return false
+}
+
+// IsDeadPosition reports whether neither side can win any more, whatever is
+// played. That is only when neither has enough material: bishops on the same
+// colour squares aren't a dead draw here, since a bishop pinned along that
+// colour can step onto a mine and uncover its king.
+func (p Position) IsDeadPosition() bool {
+	return InsufficientMaterial(p, White) && InsufficientMaterial(p, Black)
+}
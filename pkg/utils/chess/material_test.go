@@ -0,0 +1,52 @@
+package chess
+
+import "testing"
+
+func TestIsDeadPosition(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		dead bool
+	}{
+		{"bare kings", "4k3/8/8/8/8/8/8/K7 w - - 0 1", true},
+		{"king and bishop against a king", "4k3/8/8/8/8/2B5/8/K7 w - - 0 1", true},
+		{"king and knight against a king", "4k3/8/8/8/8/2N5/8/K7 w - - 0 1", true},
+		{"bishops on the same colour squares", "4k3/8/8/4b3/8/2B5/8/K7 w - - 0 1", false},
+		{"bishops on opposite colour squares", "4k3/8/8/3b4/8/2B5/8/K7 w - - 0 1", false},
+		{"king and rook against a king", "4k3/8/8/8/8/2R5/8/K7 w - - 0 1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, _, err := ParseFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pos.IsDeadPosition(); got != tt.dead {
+				t.Errorf("IsDeadPosition() = %v, want %v", got, tt.dead)
+			}
+		})
+	}
+}
+
+// The bishops are both on dark squares, but the white one is pinned: stepping
+// along the pin onto a mine blows it up and uncovers its king
+func TestSameColourBishopsCanBombmate(t *testing.T) {
+	pos, _, err := ParseFEN("4k3/8/8/4b3/8/2B5/8/K7 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mine := Square{X: 3, Y: 4} // d4
+	_, events, err := pos.Apply(Move{From: Square{X: 2, Y: 5}, To: mine}, &mine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range events {
+		if event.Type == EventBombmate {
+			if event.Piece.Color != White {
+				t.Errorf("bombmate lost by %s, want white", event.Piece.Color)
+			}
+			return
+		}
+	}
+	t.Errorf("no bombmate in %v", events)
+}
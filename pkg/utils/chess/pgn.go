@@ -0,0 +1,245 @@
+package chess
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	ResultWhiteWins = "1-0"
+	ResultBlackWins = "0-1"
+	ResultDraw      = "1/2-1/2"
+	ResultOngoing   = "*"
+)
+
+type PGNTag struct {
+	Name  string
+	Value string
+}
+
+// PGNPly is one half-move of the movetext. Mine is the square the mover armed
+// with this ply, recorded in PGN as a {[%mine c6]} comment.
+type PGNPly struct {
+	SAN  string
+	Mine *Square
+}
+
+type PGNGame struct {
+	Tags   []PGNTag
+	Plies  []PGNPly
+	Result string
+}
+
+func (g PGNGame) Tag(name string) (string, bool) {
+	for _, tag := range g.Tags {
+		if tag.Name == name {
+			return tag.Value, true
+		}
+	}
+	return "", false
+}
+
+// StartPosition is the position named by the FEN tag, or the standard
+// starting position when there is none
+func (g PGNGame) StartPosition() Position {
+	if fen, ok := g.Tag("FEN"); ok {
+		if pos, _, err := ParseFEN(fen); err == nil {
+			return pos
+		}
+	}
+	return StartingPosition()
+}
+
+// String writes the game out as PGN
+func (g PGNGame) String() string {
+	var out strings.Builder
+	for _, tag := range g.Tags {
+		value := strings.ReplaceAll(tag.Value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		fmt.Fprintf(&out, "[%s \"%s\"]\n", tag.Name, value)
+	}
+	out.WriteString("\n")
+
+	// games set up from a FEN can start on any move number, and with black
+	start := g.StartPosition()
+	offset := 2 * (start.FullmoveNumber - 1)
+	if start.ToMove == Black {
+		offset++
+	}
+	tokens := []string{}
+	for i, ply := range g.Plies {
+		n := offset + i
+		if n%2 == 0 {
+			tokens = append(tokens, fmt.Sprintf("%d.", n/2+1))
+		} else if i == 0 || g.Plies[i-1].Mine != nil {
+			// black's move number is repeated after a comment
+			tokens = append(tokens, fmt.Sprintf("%d...", n/2+1))
+		}
+		tokens = append(tokens, ply.SAN)
+		if ply.Mine != nil {
+			tokens = append(tokens, fmt.Sprintf("{[%%mine %s]}", ply.Mine))
+		}
+	}
+	result := g.Result
+	if result == "" {
+		result = ResultOngoing
+	}
+	tokens = append(tokens, result)
+
+	// keep lines under 80 characters as the export format asks
+	line := 0
+	for i, token := range tokens {
+		if i > 0 {
+			if line+1+len(token) > 79 {
+				out.WriteString("\n")
+				line = 0
+			} else {
+				out.WriteString(" ")
+				line++
+			}
+		}
+		out.WriteString(token)
+		line += len(token)
+	}
+	out.WriteString("\n")
+	return out.String()
+}
+
+var (
+	tagPattern  = regexp.MustCompile(`^\[\s*(\w+)\s+"((?:[^"\\]|\\.)*)"\s*\]$`)
+	minePattern = regexp.MustCompile(`\[%mine\s+([a-h][1-8])\]`)
+	moveNumber  = regexp.MustCompile(`^\d+\.+`)
+)
+
+// ParsePGN reads a single game. Variations, NAGs and comments other than mine
+// placements are skipped. The moves themselves are checked by Replay.
+func ParsePGN(text string) (PGNGame, error) {
+	game := PGNGame{}
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	i := 0
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "[") {
+			break
+		}
+		match := tagPattern.FindStringSubmatch(line)
+		if match == nil {
+			return game, fmt.Errorf("invalid tag pair: %s", line)
+		}
+		value := strings.ReplaceAll(match[2], `\"`, `"`)
+		value = strings.ReplaceAll(value, `\\`, `\`)
+		game.Tags = append(game.Tags, PGNTag{Name: match[1], Value: value})
+	}
+
+	movetext := strings.Join(lines[i:], "\n")
+	depth := 0 // variation nesting
+	for pos := 0; pos < len(movetext); {
+		c := movetext[pos]
+		switch {
+		case c == ' ' || c == '\n' || c == '\t':
+			pos++
+		case c == '{':
+			end := strings.IndexByte(movetext[pos:], '}')
+			if end < 0 {
+				return game, errors.New("unterminated comment")
+			}
+			comment := movetext[pos+1 : pos+end]
+			pos += end + 1
+			if depth > 0 {
+				continue
+			}
+			if match := minePattern.FindStringSubmatch(comment); match != nil {
+				if len(game.Plies) == 0 {
+					return game, errors.New("mine comment before the first move")
+				}
+				mine, _ := ParseSquare(match[1])
+				game.Plies[len(game.Plies)-1].Mine = &mine
+			}
+		case c == ';':
+			end := strings.IndexByte(movetext[pos:], '\n')
+			if end < 0 {
+				end = len(movetext) - pos
+			}
+			pos += end
+		case c == '(':
+			depth++
+			pos++
+		case c == ')':
+			if depth == 0 {
+				return game, errors.New("unbalanced variation")
+			}
+			depth--
+			pos++
+		default:
+			end := pos
+			for end < len(movetext) && !strings.ContainsRune(" \n\t{}();", rune(movetext[end])) {
+				end++
+			}
+			token := movetext[pos:end]
+			pos = end
+			if depth > 0 || strings.HasPrefix(token, "$") {
+				continue
+			}
+			token = moveNumber.ReplaceAllString(token, "")
+			switch token {
+			case "":
+				continue
+			case ResultWhiteWins, ResultBlackWins, ResultDraw, ResultOngoing:
+				game.Result = token
+				continue
+			}
+			if game.Result != "" {
+				return game, fmt.Errorf("move %s after the game result", token)
+			}
+			game.Plies = append(game.Plies, PGNPly{SAN: token})
+		}
+	}
+	if depth != 0 {
+		return game, errors.New("unbalanced variation")
+	}
+	if game.Result == "" {
+		game.Result, _ = game.Tag("Result")
+	}
+	return game, nil
+}
+
+// Replay checks the game's moves from start, with mine armed against the first
+// mover, and returns them. It fails on the first illegal move or on any move
+// after the game has ended.
+func (g PGNGame) Replay(start Position, mine *Square) ([]Move, error) {
+	moves := make([]Move, 0, len(g.Plies))
+	pos := start
+	for i, ply := range g.Plies {
+		m, err := pos.ParseSAN(ply.SAN)
+		if err != nil {
+			return nil, fmt.Errorf("ply %d: %w", i+1, err)
+		}
+		next, events, err := pos.Apply(m, mine)
+		if err != nil {
+			return nil, fmt.Errorf("ply %d: %w", i+1, err)
+		}
+		if isGameOver(events) && i != len(g.Plies)-1 {
+			return nil, fmt.Errorf("ply %d: moves continue after the game ended", i+2)
+		}
+		moves = append(moves, m)
+		pos = next
+		mine = ply.Mine
+	}
+	return moves, nil
+}
+
+func isGameOver(events []Event) bool {
+	for _, event := range events {
+		switch event.Type {
+		case EventCheckmate, EventStalemate, EventBombmate:
+			return true
+		}
+	}
+	return false
+}
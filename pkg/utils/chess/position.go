@@ -0,0 +1,121 @@
+package chess
+
+import "fmt"
+
+type Color string
+
+const (
+	White Color = "white"
+	Black Color = "black"
+)
+
+func (c Color) Opponent() Color {
+	if c == White {
+		return Black
+	}
+	return White
+}
+
+type PieceType string
+
+const (
+	King   PieceType = "king"
+	Queen  PieceType = "queen"
+	Rook   PieceType = "rook"
+	Bishop PieceType = "bishop"
+	Knight PieceType = "knight"
+	Pawn   PieceType = "pawn"
+)
+
+// Square is a board coordinate. Y grows downwards, so Y == 0 is the 8th rank,
+// matching the layout the frontend sends and renders.
+type Square struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+func (s Square) InBounds() bool {
+	return s.X >= 0 && s.X < 8 && s.Y >= 0 && s.Y < 8
+}
+
+// String returns the algebraic name of the square, e.g. "e4".
+func (s Square) String() string {
+	return fmt.Sprintf("%c%d", s.X+'a', 8-s.Y)
+}
+
+func (s Square) offset(dx, dy int) Square {
+	return Square{X: s.X + dx, Y: s.Y + dy}
+}
+
+// Piece is a value type; the zero Piece represents an empty square.
+type Piece struct {
+	Type     PieceType `json:"type"`
+	Color    Color     `json:"color"`
+	HasMoved bool      `json:"hasMoved"`
+}
+
+func (p Piece) IsEmpty() bool {
+	return p.Type == ""
+}
+
+// Board is indexed [y][x]. Being an array it is copied on assignment, which is
+// what keeps Position immutable.
+type Board [8][8]Piece
+
+func (b *Board) At(sq Square) Piece {
+	return b[sq.Y][sq.X]
+}
+
+func (b *Board) set(sq Square, p Piece) {
+	b[sq.Y][sq.X] = p
+}
+
+// Position is everything needed to generate moves. Methods never modify the
+// receiver; Apply returns a fresh Position instead.
+type Position struct {
+	Board          Board   `json:"board"`
+	ToMove         Color   `json:"toMove"`
+	EnPassant      *Square `json:"enPassant"`      // square a pawn may capture onto, if any
+	HalfmoveClock  int     `json:"halfmoveClock"`  // plies since the last pawn move, capture or explosion
+	FullmoveNumber int     `json:"fullmoveNumber"` // starts at 1 and goes up after each black move
+	Options        Options `json:"options"`
+}
+
+// Options are variant switches that change what a move does, but never which
+// moves are legal, since that mustn't depend on the hidden mine.
+type Options struct {
+	RookDetonatesMine bool `json:"rookDetonatesMine"` // a castling rook sets off a mine on its landing square
+}
+
+func StartingPosition() Position {
+	pos := Position{ToMove: White, FullmoveNumber: 1}
+	backRank := []PieceType{Rook, Knight, Bishop, Queen, King, Bishop, Knight, Rook}
+	for x, t := range backRank {
+		pos.Board[0][x] = Piece{Type: t, Color: Black}
+		pos.Board[7][x] = Piece{Type: t, Color: White}
+		pos.Board[1][x] = Piece{Type: Pawn, Color: Black}
+		pos.Board[6][x] = Piece{Type: Pawn, Color: White}
+	}
+	return pos
+}
+
+// KingSquare returns the square of the given colour's king.
+func (p Position) KingSquare(c Color) (Square, bool) {
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if piece := p.Board[y][x]; piece.Type == King && piece.Color == c {
+				return Square{X: x, Y: y}, true
+			}
+		}
+	}
+	return Square{}, false
+}
+
+// InCheck reports whether the given colour's king is attacked.
+func (p Position) InCheck(c Color) bool {
+	king, ok := p.KingSquare(c)
+	if !ok {
+		return false
+	}
+	return p.IsSquareAttacked(king, c.Opponent())
+}
@@ -0,0 +1,61 @@
+package chess
+
+import (
+	"errors"
+	"testing"
+)
+
+var (
+	a7 = Square{X: 0, Y: 1}
+	a8 = Square{X: 0, Y: 0}
+)
+
+func TestValidatePromotion(t *testing.T) {
+	const fen = "4k3/P7/8/8/8/8/1P6/1N2K3 w - - 0 1"
+	tests := []struct {
+		name string
+		move Move
+		ok   bool
+	}{
+		{"to a queen", Move{From: a7, To: a8, Promotion: Queen}, true},
+		{"to a knight", Move{From: a7, To: a8, Promotion: Knight}, true},
+		{"missing on the last rank", Move{From: a7, To: a8}, false},
+		{"to a king", Move{From: a7, To: a8, Promotion: King}, false},
+		{"to a pawn", Move{From: a7, To: a8, Promotion: Pawn}, false},
+		{"before the last rank", Move{From: Square{X: 1, Y: 6}, To: Square{X: 1, Y: 5}, Promotion: Queen}, false},
+		{"of a knight", Move{From: Square{X: 1, Y: 7}, To: Square{X: 2, Y: 5}, Promotion: Queen}, false},
+		{"no promotion", Move{From: Square{X: 1, Y: 6}, To: Square{X: 1, Y: 5}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, _, err := ParseFEN(fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = pos.ValidatePromotion(tt.move)
+			if tt.ok && err != nil {
+				t.Errorf("got %v, want the promotion", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrIllegalMove) {
+				t.Errorf("got %v, want an illegal move", err)
+			}
+		})
+	}
+}
+
+// A pawn reaching the last rank has one legal move per promotion piece
+func TestLegalMovesListPromotions(t *testing.T) {
+	pos, _, err := ParseFEN("4k3/P7/8/8/8/8/8/4K3 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	moves := pos.LegalMovesFrom(a7)
+	if len(moves) != len(PromotionPieces) {
+		t.Fatalf("got %v, want one move per promotion piece", moves)
+	}
+	for i, m := range moves {
+		if m.To != a8 || m.Promotion != PromotionPieces[i] {
+			t.Errorf("move %d is %+v, want a8=%s", i, m, PromotionPieces[i])
+		}
+	}
+}
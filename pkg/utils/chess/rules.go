@@ -1 +1,435 @@
 package chess
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrIllegalMove = errors.New("illegal move")
+
+type Move struct {
+	From      Square    `json:"from"`
+	To        Square    `json:"to"`
+	Promotion PieceType `json:"promotion,omitempty"`
+}
+
+type EventType string
+
+const (
+	EventCapture   EventType = "capture"
+	EventEnPassant EventType = "enPassant"
+	EventCastle    EventType = "castle"
+	EventPromotion EventType = "promotion"
+	EventExplosion EventType = "explosion"
+	EventCheck     EventType = "check"
+	EventBombmate  EventType = "bombmate"
+	EventCheckmate EventType = "checkmate"
+	EventStalemate EventType = "stalemate"
+)
+
+// Event describes something that happened while applying a move.
+//
+//   - EventCapture: Piece was taken on Square (for en passant, the square of the taken pawn).
+//   - EventCastle: the rook moved From -> Square.
+//   - EventPromotion: the pawn on Square became Piece.
+//   - EventExplosion: Piece stepped on the mine at Square. Kings survive the blast.
+//   - EventBombmate: the explosion exposed the mover's king; Piece.Color lost.
+//   - EventCheck, EventCheckmate, EventStalemate: refer to the side now to move.
+type Event struct {
+	Type   EventType `json:"type"`
+	Square Square    `json:"square"`
+	From   Square    `json:"from"`
+	Piece  Piece     `json:"piece"`
+}
+
+var (
+	rookDirs   = []Square{{X: 1, Y: 0}, {X: -1, Y: 0}, {X: 0, Y: 1}, {X: 0, Y: -1}}
+	bishopDirs = []Square{{X: 1, Y: 1}, {X: 1, Y: -1}, {X: -1, Y: 1}, {X: -1, Y: -1}}
+	kingDirs   = append(append([]Square{}, rookDirs...), bishopDirs...)
+	knightDirs = []Square{{X: 2, Y: 1}, {X: 2, Y: -1}, {X: -2, Y: 1}, {X: -2, Y: -1}, {X: 1, Y: 2}, {X: 1, Y: -2}, {X: -1, Y: 2}, {X: -1, Y: -2}}
+)
+
+func pawnDir(c Color) int {
+	if c == White {
+		return -1
+	}
+	return 1
+}
+
+// IsSquareAttacked reports whether any piece of colour by attacks sq.
+func (p Position) IsSquareAttacked(sq Square, by Color) bool {
+	for _, dir := range rookDirs {
+		for t := sq.offset(dir.X, dir.Y); t.InBounds(); t = t.offset(dir.X, dir.Y) {
+			piece := p.Board.At(t)
+			if piece.IsEmpty() {
+				continue
+			}
+			if piece.Color == by && (piece.Type == Queen || piece.Type == Rook) {
+				return true
+			}
+			break
+		}
+	}
+	for _, dir := range bishopDirs {
+		for t := sq.offset(dir.X, dir.Y); t.InBounds(); t = t.offset(dir.X, dir.Y) {
+			piece := p.Board.At(t)
+			if piece.IsEmpty() {
+				continue
+			}
+			if piece.Color == by && (piece.Type == Queen || piece.Type == Bishop) {
+				return true
+			}
+			break
+		}
+	}
+	for _, dir := range knightDirs {
+		t := sq.offset(dir.X, dir.Y)
+		if t.InBounds() && p.Board.At(t).Type == Knight && p.Board.At(t).Color == by {
+			return true
+		}
+	}
+	for _, dir := range kingDirs {
+		t := sq.offset(dir.X, dir.Y)
+		if t.InBounds() && p.Board.At(t).Type == King && p.Board.At(t).Color == by {
+			return true
+		}
+	}
+	// a pawn of colour by attacks sq from one rank behind it
+	for _, dx := range []int{-1, 1} {
+		t := sq.offset(dx, -pawnDir(by))
+		if t.InBounds() && p.Board.At(t).Type == Pawn && p.Board.At(t).Color == by {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateLegalMoves returns every legal move for the side to move. The mine
+// is deliberately not an input: it is hidden, so legality never depends on it.
+func GenerateLegalMoves(pos Position) []Move {
+	moves := []Move{}
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if pos.Board[y][x].Color == pos.ToMove && !pos.Board[y][x].IsEmpty() {
+				moves = append(moves, pos.LegalMovesFrom(Square{X: x, Y: y})...)
+			}
+		}
+	}
+	return moves
+}
+
+// LegalMovesFrom returns the legal moves of the piece on sq, which must belong
+// to the side to move.
+func (p Position) LegalMovesFrom(sq Square) []Move {
+	piece := p.Board.At(sq)
+	if piece.IsEmpty() || piece.Color != p.ToMove {
+		return nil
+	}
+	legal := []Move{}
+	for _, m := range p.pseudoMoves(sq, piece) {
+		next := p.move(m)
+		if !next.InCheck(piece.Color) {
+			legal = append(legal, m)
+		}
+	}
+	return legal
+}
+
+// IsLegal reports whether m is one of the legal moves in the position. A pawn
+// reaching the last rank must name its promotion, and nothing else may.
+func (p Position) IsLegal(m Move) bool {
+	if !m.From.InBounds() || !m.To.InBounds() {
+		return false
+	}
+	for _, legal := range p.LegalMovesFrom(m.From) {
+		if legal == m {
+			return true
+		}
+	}
+	return false
+}
+
+// PromotionPieces are the piece types a pawn may promote to
+var PromotionPieces = []PieceType{Queen, Rook, Bishop, Knight}
+
+// ValidatePromotion explains what is wrong with the promotion of m, if
+// anything. It says nothing about whether the move is otherwise legal.
+func (p Position) ValidatePromotion(m Move) error {
+	if !m.From.InBounds() || !m.To.InBounds() {
+		return nil
+	}
+	piece := p.Board.At(m.From)
+	lastRank := piece.Type == Pawn && m.To.Y == homeRank(piece.Color.Opponent())
+	switch {
+	case m.Promotion == "" && lastRank:
+		return fmt.Errorf("%w: a pawn reaching the last rank must promote", ErrIllegalMove)
+	case m.Promotion == "":
+		return nil
+	case piece.Type != Pawn:
+		return fmt.Errorf("%w: only pawns can promote", ErrIllegalMove)
+	case !lastRank:
+		return fmt.Errorf("%w: pawns only promote on the last rank", ErrIllegalMove)
+	}
+	for _, pieceType := range PromotionPieces {
+		if m.Promotion == pieceType {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: a pawn cannot promote to %s", ErrIllegalMove, m.Promotion)
+}
+
+func (p Position) pseudoMoves(sq Square, piece Piece) []Move {
+	switch piece.Type {
+	case Pawn:
+		return p.pawnMoves(sq, piece)
+	case Knight:
+		return p.stepMoves(sq, piece, knightDirs)
+	case Bishop:
+		return p.slideMoves(sq, piece, bishopDirs)
+	case Rook:
+		return p.slideMoves(sq, piece, rookDirs)
+	case Queen:
+		return p.slideMoves(sq, piece, kingDirs)
+	case King:
+		return append(p.stepMoves(sq, piece, kingDirs), p.castleMoves(sq, piece)...)
+	}
+	return nil
+}
+
+func (p Position) pawnMoves(sq Square, piece Piece) []Move {
+	moves := []Move{}
+	dir := pawnDir(piece.Color)
+	one := sq.offset(0, dir)
+	if !one.InBounds() {
+		return moves
+	}
+	if p.Board.At(one).IsEmpty() {
+		moves = append(moves, pawnMove(sq, one)...)
+		two := sq.offset(0, 2*dir)
+		if !piece.HasMoved && two.InBounds() && p.Board.At(two).IsEmpty() {
+			moves = append(moves, Move{From: sq, To: two})
+		}
+	}
+	for _, dx := range []int{-1, 1} {
+		t := sq.offset(dx, dir)
+		if !t.InBounds() {
+			continue
+		}
+		target := p.Board.At(t)
+		if (!target.IsEmpty() && target.Color != piece.Color) || (p.EnPassant != nil && *p.EnPassant == t) {
+			moves = append(moves, pawnMove(sq, t)...)
+		}
+	}
+	return moves
+}
+
+// pawnMove returns the pawn move to t, or one move per promotion piece if t
+// is on the last rank
+func pawnMove(sq, t Square) []Move {
+	if t.Y != 0 && t.Y != 7 {
+		return []Move{{From: sq, To: t}}
+	}
+	moves := make([]Move, 0, len(PromotionPieces))
+	for _, pieceType := range PromotionPieces {
+		moves = append(moves, Move{From: sq, To: t, Promotion: pieceType})
+	}
+	return moves
+}
+
+func (p Position) stepMoves(sq Square, piece Piece, dirs []Square) []Move {
+	moves := []Move{}
+	for _, dir := range dirs {
+		t := sq.offset(dir.X, dir.Y)
+		if t.InBounds() && (p.Board.At(t).IsEmpty() || p.Board.At(t).Color != piece.Color) {
+			moves = append(moves, Move{From: sq, To: t})
+		}
+	}
+	return moves
+}
+
+func (p Position) slideMoves(sq Square, piece Piece, dirs []Square) []Move {
+	moves := []Move{}
+	for _, dir := range dirs {
+		for t := sq.offset(dir.X, dir.Y); t.InBounds(); t = t.offset(dir.X, dir.Y) {
+			target := p.Board.At(t)
+			if target.IsEmpty() {
+				moves = append(moves, Move{From: sq, To: t})
+				continue
+			}
+			if target.Color != piece.Color {
+				moves = append(moves, Move{From: sq, To: t})
+			}
+			break
+		}
+	}
+	return moves
+}
+
+// castleMoves returns the castling moves of an unmoved king. The king may not
+// castle out of check or through an attacked square; castling into check is
+// ruled out with every other move in LegalMovesFrom.
+func (p Position) castleMoves(sq Square, king Piece) []Move {
+	moves := []Move{}
+	enemy := king.Color.Opponent()
+	if king.HasMoved || p.IsSquareAttacked(sq, enemy) {
+		return moves
+	}
+	for _, side := range []struct{ rookX, dir int }{{0, -1}, {7, 1}} {
+		rook := p.Board[sq.Y][side.rookX]
+		if rook.Type != Rook || rook.Color != king.Color || rook.HasMoved {
+			continue
+		}
+		clear := true
+		for x := sq.X + side.dir; x != side.rookX; x += side.dir {
+			clear = clear && p.Board[sq.Y][x].IsEmpty()
+		}
+		if clear && !p.IsSquareAttacked(sq.offset(side.dir, 0), enemy) {
+			moves = append(moves, Move{From: sq, To: sq.offset(2*side.dir, 0)})
+		}
+	}
+	return moves
+}
+
+// isCastle reports whether a king move is a castling move, returning the rook's
+// from and to squares.
+func isCastle(piece Piece, m Move) (Square, Square, bool) {
+	if piece.Type != King || m.From.Y != m.To.Y {
+		return Square{}, Square{}, false
+	}
+	switch m.To.X - m.From.X {
+	case 2:
+		return Square{X: 7, Y: m.From.Y}, Square{X: m.To.X - 1, Y: m.From.Y}, true
+	case -2:
+		return Square{X: 0, Y: m.From.Y}, Square{X: m.To.X + 1, Y: m.From.Y}, true
+	}
+	return Square{}, Square{}, false
+}
+
+func isEnPassant(p Position, piece Piece, m Move) bool {
+	return piece.Type == Pawn && p.EnPassant != nil && *p.EnPassant == m.To && m.From.X != m.To.X && p.Board.At(m.To).IsEmpty()
+}
+
+// move plays m on a copy of the position without any mine or game-end logic.
+// The side to move is left unchanged so callers can test their own king.
+func (p Position) move(m Move) Position {
+	next := p
+	piece := next.Board.At(m.From)
+	if isEnPassant(p, piece, m) {
+		next.Board.set(Square{X: m.To.X, Y: m.From.Y}, Piece{})
+	}
+	if rookFrom, rookTo, ok := isCastle(piece, m); ok {
+		rook := next.Board.At(rookFrom)
+		rook.HasMoved = true
+		next.Board.set(rookFrom, Piece{})
+		next.Board.set(rookTo, rook)
+	}
+	piece.HasMoved = true
+	if m.Promotion != "" {
+		piece.Type = m.Promotion
+	}
+	next.Board.set(m.From, Piece{})
+	next.Board.set(m.To, piece)
+
+	next.EnPassant = nil
+	if piece.Type == Pawn && abs(m.To.Y-m.From.Y) == 2 {
+		next.EnPassant = &Square{X: m.From.X, Y: (m.From.Y + m.To.Y) / 2}
+	}
+	return next
+}
+
+// detonation returns the square on which m sets off the mine, if it does. That
+// is the destination of any piece but a pawn, or with RookDetonatesMine the
+// square a castling rook lands on.
+func (p Position) detonation(piece Piece, m Move, mine *Square) (Square, bool) {
+	if mine == nil {
+		return Square{}, false
+	}
+	if *mine == m.To && piece.Type != Pawn {
+		return m.To, true
+	}
+	if _, rookTo, ok := isCastle(piece, m); ok && p.Options.RookDetonatesMine && *mine == rookTo {
+		return rookTo, true
+	}
+	return Square{}, false
+}
+
+// Apply plays m and returns the resulting position together with what
+// happened. mine is the square armed by the opponent on their previous turn,
+// or nil. Any piece other than a pawn landing on it sets it off; everything
+// but a king is destroyed, and if that uncovers the mover's own king the mover
+// is bombmated.
+func (p Position) Apply(m Move, mine *Square) (Position, []Event, error) {
+	if !p.IsLegal(m) {
+		return p, nil, ErrIllegalMove
+	}
+	piece := p.Board.At(m.From)
+	events := []Event{}
+
+	if target := p.Board.At(m.To); !target.IsEmpty() {
+		events = append(events, Event{Type: EventCapture, Square: m.To, Piece: target})
+	}
+	if isEnPassant(p, piece, m) {
+		taken := Square{X: m.To.X, Y: m.From.Y}
+		events = append(events, Event{Type: EventEnPassant, Square: taken, Piece: p.Board.At(taken)})
+		events = append(events, Event{Type: EventCapture, Square: taken, Piece: p.Board.At(taken)})
+	}
+	if rookFrom, rookTo, ok := isCastle(piece, m); ok {
+		events = append(events, Event{Type: EventCastle, From: rookFrom, Square: rookTo, Piece: p.Board.At(rookFrom)})
+	}
+
+	next := p.move(m)
+	if m.Promotion != "" {
+		events = append(events, Event{Type: EventPromotion, Square: m.To, Piece: next.Board.At(m.To)})
+	}
+
+	bombmated := false
+	if blast, ok := p.detonation(piece, m, mine); ok {
+		blown := next.Board.At(blast)
+		events = append(events, Event{Type: EventExplosion, Square: blast, Piece: blown})
+		if blown.Type != King {
+			next.Board.set(blast, Piece{})
+		}
+		if next.InCheck(piece.Color) {
+			bombmated = true
+			events = append(events, Event{Type: EventBombmate, Square: blast, Piece: blown})
+		}
+	}
+
+	next.ToMove = p.ToMove.Opponent()
+	next.HalfmoveClock = p.HalfmoveClock + 1
+	for _, event := range events {
+		if event.Type == EventCapture || (event.Type == EventExplosion && event.Piece.Type != King) {
+			next.HalfmoveClock = 0
+		}
+	}
+	if piece.Type == Pawn {
+		next.HalfmoveClock = 0
+	}
+	if p.ToMove == Black {
+		next.FullmoveNumber = p.FullmoveNumber + 1
+	}
+	if bombmated {
+		return next, events, nil
+	}
+
+	inCheck := next.InCheck(next.ToMove)
+	if inCheck {
+		king, _ := next.KingSquare(next.ToMove)
+		events = append(events, Event{Type: EventCheck, Square: king})
+	}
+	if len(GenerateLegalMoves(next)) == 0 {
+		if inCheck {
+			events = append(events, Event{Type: EventCheckmate})
+		} else {
+			events = append(events, Event{Type: EventStalemate})
+		}
+	}
+	return next, events, nil
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,115 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+)
+
+var pieceLetters = map[PieceType]string{
+	King:   "K",
+	Queen:  "Q",
+	Rook:   "R",
+	Bishop: "B",
+	Knight: "N",
+	Pawn:   "",
+}
+
+// ParseSquare reads an algebraic square name such as "e4"
+func ParseSquare(name string) (Square, error) {
+	if len(name) != 2 || name[0] < 'a' || name[0] > 'h' || name[1] < '1' || name[1] > '8' {
+		return Square{}, fmt.Errorf("invalid square: %q", name)
+	}
+	return Square{X: int(name[0] - 'a'), Y: 8 - int(name[1]-'0')}, nil
+}
+
+// SAN returns the standard algebraic notation of a legal move. The check or
+// mate suffix takes the armed mine into account, since an explosion can remove
+// the checking piece.
+func (p Position) SAN(m Move, mine *Square) string {
+	san := p.sanBody(m)
+	_, events, err := p.Apply(m, mine)
+	if err != nil {
+		return san
+	}
+	suffix := ""
+	for _, event := range events {
+		switch event.Type {
+		case EventCheck:
+			suffix = "+"
+		case EventCheckmate:
+			return san + "#"
+		}
+	}
+	return san + suffix
+}
+
+// sanBody is the SAN of m without the check suffix
+func (p Position) sanBody(m Move) string {
+	piece := p.Board.At(m.From)
+	if rookFrom, _, ok := isCastle(piece, m); ok {
+		if rookFrom.X == 0 {
+			return "O-O-O"
+		}
+		return "O-O"
+	}
+
+	capture := !p.Board.At(m.To).IsEmpty() || isEnPassant(p, piece, m)
+	var san strings.Builder
+	if piece.Type == Pawn {
+		if capture {
+			san.WriteByte(byte('a' + m.From.X))
+		}
+	} else {
+		san.WriteString(pieceLetters[piece.Type])
+		san.WriteString(p.disambiguation(m, piece))
+	}
+	if capture {
+		san.WriteString("x")
+	}
+	san.WriteString(m.To.String())
+	if m.Promotion != "" {
+		san.WriteString("=" + pieceLetters[m.Promotion])
+	}
+	return san.String()
+}
+
+// disambiguation returns the file, rank or square needed to tell m apart from
+// other legal moves of the same piece type to the same square
+func (p Position) disambiguation(m Move, piece Piece) string {
+	ambiguous, sameFile, sameRank := false, false, false
+	for _, other := range GenerateLegalMoves(p) {
+		if other.To != m.To || other.From == m.From || p.Board.At(other.From).Type != piece.Type {
+			continue
+		}
+		ambiguous = true
+		sameFile = sameFile || other.From.X == m.From.X
+		sameRank = sameRank || other.From.Y == m.From.Y
+	}
+	switch {
+	case !ambiguous:
+		return ""
+	case !sameFile:
+		return m.From.String()[:1]
+	case !sameRank:
+		return m.From.String()[1:]
+	}
+	return m.From.String()
+}
+
+// ParseSAN finds the legal move written as san. Check, mate and annotation
+// suffixes are optional.
+func (p Position) ParseSAN(san string) (Move, error) {
+	want := normalizeSAN(san)
+	for _, m := range GenerateLegalMoves(p) {
+		if normalizeSAN(p.sanBody(m)) == want {
+			return m, nil
+		}
+	}
+	return Move{}, fmt.Errorf("%w: %s", ErrIllegalMove, san)
+}
+
+func normalizeSAN(san string) string {
+	san = strings.TrimRight(san, "+#!?")
+	san = strings.ReplaceAll(san, "0", "O")
+	return san
+}
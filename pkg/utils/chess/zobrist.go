@@ -0,0 +1,76 @@
+package chess
+
+// Zobrist keys, one per piece on each square plus the side to move, each
+// castling right and each en passant file. They're generated from a fixed
+// seed so a hash means the same thing across restarts.
+var (
+	zobristPieces    [2][6][64]uint64
+	zobristBlack     uint64
+	zobristCastling  = map[rune]uint64{}
+	zobristEnPassant [8]uint64
+)
+
+var zobristPieceIndex = map[PieceType]int{King: 0, Queen: 1, Rook: 2, Bishop: 3, Knight: 4, Pawn: 5}
+
+func init() {
+	seed := uint64(0x6d696e6563686573) // "minechess"
+	next := func() uint64 {
+		// splitmix64
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		return z ^ (z >> 31)
+	}
+	for c := range zobristPieces {
+		for t := range zobristPieces[c] {
+			for sq := range zobristPieces[c][t] {
+				zobristPieces[c][t][sq] = next()
+			}
+		}
+	}
+	zobristBlack = next()
+	for _, right := range "KQkq" {
+		zobristCastling[right] = next()
+	}
+	for file := range zobristEnPassant {
+		zobristEnPassant[file] = next()
+	}
+}
+
+// Hash returns the Zobrist hash of the position for repetition detection. Two
+// positions hash the same when they have the same pieces, side to move,
+// castling rights and en passant capture. The mine isn't part of it, since a
+// new one is laid with every move and no position would ever repeat.
+func (p Position) Hash() uint64 {
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			piece := p.Board[y][x]
+			if piece.IsEmpty() {
+				continue
+			}
+			color := 0
+			if piece.Color == Black {
+				color = 1
+			}
+			hash ^= zobristPieces[color][zobristPieceIndex[piece.Type]][y*8+x]
+		}
+	}
+	if p.ToMove == Black {
+		hash ^= zobristBlack
+	}
+	for _, right := range p.CastlingRights() {
+		hash ^= zobristCastling[right]
+	}
+	// the en passant square only counts if a pawn can actually take there
+	if p.EnPassant != nil {
+		for _, m := range GenerateLegalMoves(p) {
+			if isEnPassant(p, p.Board.At(m.From), m) {
+				hash ^= zobristEnPassant[p.EnPassant.X]
+				break
+			}
+		}
+	}
+	return hash
+}